@@ -1,3 +1,5 @@
 module github.com/bocianowski1/go-ur
 
 go 1.22.1
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,359 @@
+package ur
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditKind is the type of an audit log entry
+type AuditKind string
+
+const (
+	AUDIT_PROGRAM      AuditKind = "program"
+	AUDIT_DASHBOARD    AuditKind = "dashboard"
+	AUDIT_RTDE_SETUP   AuditKind = "rtde_setup"
+	AUDIT_TEXT_MESSAGE AuditKind = "text_message"
+	AUDIT_CONNECT      AuditKind = "connect"
+	AUDIT_DISCONNECT   AuditKind = "disconnect"
+)
+
+// DASHBOARD_PORT is the port of the dashboard server. Commands sent on a connection to it
+// are audited as dashboard commands.
+const DASHBOARD_PORT = 29999
+
+const (
+	DEFAULT_AUDIT_MAX_SIZE  = 10 << 20 // bytes
+	DEFAULT_AUDIT_MAX_FILES = 10
+)
+
+const auditTimeFormat = "20060102T150405.000000000"
+
+// AuditEntry is one line of the audit log
+type AuditEntry struct {
+	Time          time.Time         `json:"time"`
+	Kind          AuditKind         `json:"kind"`
+	ConnectionID  string            `json:"connection_id,omitempty"`
+	Address       string            `json:"address,omitempty"`
+	Caller        string            `json:"caller,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Text          string            `json:"text,omitempty"`
+	Hash          string            `json:"hash,omitempty"` // sha256 of Text
+	Level         string            `json:"level,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	DryRun        bool              `json:"dry_run,omitempty"` // the command was not sent
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+type AuditOptions struct {
+	MaxSize  int64 // size at which the file is rotated
+	MaxFiles int   // rotated files to keep, 0 keeps all
+}
+
+type AuditOption func(*AuditOptions)
+
+func WithMaxSize(bytes int64) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.MaxSize = bytes
+	}
+}
+
+func WithMaxFiles(n int) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.MaxFiles = n
+	}
+}
+
+// AuditLog is an append-only JSON Lines log of what the robot was told and what it reported.
+// When the file reaches MaxSize it is renamed to <path>.<timestamp> and a new file is started.
+type AuditLog struct {
+	path string
+	opts AuditOptions
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func OpenAuditLog(path string, options ...AuditOption) (*AuditLog, error) {
+	opts := AuditOptions{
+		MaxSize:  DEFAULT_AUDIT_MAX_SIZE,
+		MaxFiles: DEFAULT_AUDIT_MAX_FILES,
+	}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	l := &AuditLog{path: path, opts: opts}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *AuditLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// Record appends an entry. The hash is computed from the text when not set.
+func (l *AuditLog) Record(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.Hash == "" && entry.Text != "" {
+		entry.Hash = hashText(entry.Text)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("audit log %s is closed", l.path)
+	}
+	if l.opts.MaxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.opts.MaxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// rotate renames the current file and removes the oldest rotated files. The caller holds mu.
+func (l *AuditLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+
+	rotated := l.path + "." + time.Now().UTC().Format(auditTimeFormat)
+	if err := os.Rename(l.path, rotated); err != nil {
+		return err
+	}
+
+	if l.opts.MaxFiles > 0 {
+		files, err := rotatedAuditFiles(l.path)
+		if err != nil {
+			return err
+		}
+		for len(files) > l.opts.MaxFiles {
+			if err := os.Remove(files[0]); err != nil {
+				return err
+			}
+			files = files[1:]
+		}
+	}
+
+	return l.open()
+}
+
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Middleware records every command sent through the chain
+func (l *AuditLog) Middleware(connectionID func() string, kind AuditKind) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return func(ctx context.Context, cmd *Command) error {
+			err := next(ctx, cmd)
+
+			entry := AuditEntry{
+				Time:          cmd.Time,
+				Kind:          kind,
+				ConnectionID:  connectionID(),
+				Caller:        cmd.Caller,
+				CorrelationID: cmd.CorrelationID,
+				Text:          cmd.Text,
+				DryRun:        cmd.DryRun,
+				Metadata:      cmd.Metadata,
+			}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			if recordErr := l.Record(entry); recordErr != nil && err == nil {
+				return fmt.Errorf("audit log: %v", recordErr)
+			}
+			return err
+		}
+	}
+}
+
+// ReadAuditLog returns the entries of the log and its rotated files written in [from, to].
+// A zero from or to leaves that end of the range open.
+func ReadAuditLog(path string, from, to time.Time) ([]AuditEntry, error) {
+	files, err := rotatedAuditFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, path)
+
+	var entries []AuditEntry
+	for _, name := range files {
+		file, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16<<20)
+		line := 0
+		for scanner.Scan() {
+			line++
+			var entry AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("%s:%d: %v", name, line, err)
+			}
+			if !from.IsZero() && entry.Time.Before(from) {
+				continue
+			}
+			if !to.IsZero() && entry.Time.After(to) {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
+
+// rotatedAuditFiles returns the rotated files of a log, oldest first
+func rotatedAuditFiles(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, m := range matches {
+		suffix := strings.TrimPrefix(m, path+".")
+		if _, err := time.Parse(auditTimeFormat, suffix); err == nil {
+			files = append(files, m)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func hashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetAuditLog records connections, sent commands and, for receivers, RTDE setups and
+// text messages in the audit log. Calling it again switches logs; nil stops auditing.
+func (c *URCommon) SetAuditLog(l *AuditLog) {
+	c.mu.Lock()
+	c.audit = l
+	install := !c.auditInstalled
+	c.auditInstalled = true
+	c.mu.Unlock()
+
+	if install {
+		c.Use(c.auditMiddleware)
+	}
+}
+
+// auditMiddleware records commands in the audit log that is set when they are sent
+func (c *URCommon) auditMiddleware(next CommandHandler) CommandHandler {
+	return func(ctx context.Context, cmd *Command) error {
+		c.mu.RLock()
+		audit := c.audit
+		c.mu.RUnlock()
+
+		if audit == nil {
+			return next(ctx, cmd)
+		}
+
+		kind := AUDIT_PROGRAM
+		if c.cfg.Port == DASHBOARD_PORT {
+			kind = AUDIT_DASHBOARD
+		}
+		return audit.Middleware(c.connectionID, kind)(next)(ctx, cmd)
+	}
+}
+
+// connectionID returns the ID of the current connection, or "" when disconnected
+func (c *URCommon) connectionID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.conn == nil {
+		return ""
+	}
+	return c.conn.id
+}
+
+// recordAudit writes an entry for the current connection when an audit log is set
+func (c *URCommon) recordAudit(entry AuditEntry) {
+	c.mu.RLock()
+	cn := c.conn
+	c.mu.RUnlock()
+	c.recordAuditFor(cn, entry)
+}
+
+// recordAuditFor writes an entry for the given connection when an audit log is set
+func (c *URCommon) recordAuditFor(cn *connection, entry AuditEntry) {
+	c.mu.RLock()
+	audit := c.audit
+	c.mu.RUnlock()
+
+	if audit == nil {
+		return
+	}
+	if cn != nil {
+		entry.ConnectionID = cn.id
+	}
+	if err := audit.Record(entry); err != nil {
+		slog.Warn("Failed to write audit log", "error", err)
+	}
+}
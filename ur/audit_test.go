@@ -0,0 +1,129 @@
+package ur
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadAuditLogTimeRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// Written out of order; ReadAuditLog sorts by time
+	for _, h := range []int{2, 0, 1, 3} {
+		entry := AuditEntry{Time: t0.Add(time.Duration(h) * time.Hour), Kind: AUDIT_PROGRAM, Text: fmt.Sprintf("program %d", h)}
+		if err := l.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []string
+	}{
+		{"open range", time.Time{}, time.Time{}, []string{"program 0", "program 1", "program 2", "program 3"}},
+		{"from", t0.Add(time.Hour), time.Time{}, []string{"program 1", "program 2", "program 3"}},
+		{"to", time.Time{}, t0.Add(time.Hour), []string{"program 0", "program 1"}},
+		{"inclusive bounds", t0.Add(time.Hour), t0.Add(2 * time.Hour), []string{"program 1", "program 2"}},
+		{"between entries", t0.Add(10 * time.Minute), t0.Add(50 * time.Minute), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ReadAuditLog(path, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.want) {
+				t.Fatalf("ReadAuditLog() returned %d entries, want %d", len(entries), len(tt.want))
+			}
+			for i, e := range entries {
+				if e.Text != tt.want[i] {
+					t.Errorf("entry %d = %q, want %q", i, e.Text, tt.want[i])
+				}
+				if e.Hash != hashText(e.Text) {
+					t.Errorf("entry %d hash = %q, want the hash of its text", i, e.Hash)
+				}
+			}
+		})
+	}
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxFiles int
+		entries  int
+		rotated  int // rotated files left
+		readable int // entries ReadAuditLog still finds
+	}{
+		{"no rotation", 3, 2, 0, 2},
+		{"keeps all rotated files", 0, 10, 4, 10},
+		{"removes the oldest files", 2, 10, 2, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+			// Each entry is about 150 bytes, so two fit in a file
+			l, err := OpenAuditLog(path, WithMaxSize(400), WithMaxFiles(tt.maxFiles))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.entries; i++ {
+				if err := l.Record(AuditEntry{Kind: AUDIT_DASHBOARD, Text: fmt.Sprintf("command %d", i)}); err != nil {
+					t.Fatal(err)
+				}
+				// Rotated files are named by time
+				time.Sleep(time.Millisecond)
+			}
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			rotated, err := rotatedAuditFiles(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rotated) != tt.rotated {
+				t.Errorf("%d rotated file(s), want %d", len(rotated), tt.rotated)
+			}
+			for _, name := range append(rotated, path) {
+				info, err := os.Stat(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Size() > 400 {
+					t.Errorf("%s is %d bytes, want at most 400", name, info.Size())
+				}
+			}
+
+			entries, err := ReadAuditLog(path, time.Time{}, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.readable {
+				t.Fatalf("ReadAuditLog() returned %d entries, want %d", len(entries), tt.readable)
+			}
+			// The newest entries survive, in order
+			first := tt.entries - tt.readable
+			for i, e := range entries {
+				if want := fmt.Sprintf("command %d", first+i); e.Text != want {
+					t.Errorf("entry %d = %q, want %q", i, e.Text, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package ur
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Command is a program or statement on its way to the robot
+type Command struct {
+	Text          string
+	Moves         []MoveCmd // moves the program was built from, nil for raw programs
+	Caller        string    // function that sent the command, e.g. "ur.(*URController).MoveJ"
+	CorrelationID string
+	Metadata      map[string]string
+	Time          time.Time
+	DryRun        bool // set when a dry run recorded the command instead of sending it
+}
+
+// CommandHandler handles an outgoing command
+type CommandHandler func(ctx context.Context, cmd *Command) error
+
+// Middleware wraps a CommandHandler. It can inspect or modify the command before
+// calling next, or reject it by returning an error without calling next.
+type Middleware func(next CommandHandler) CommandHandler
+
+// CommandRejectedError is returned when a middleware refuses a command
+type CommandRejectedError struct {
+	Reason string
+	Cmd    *Command
+}
+
+func (e *CommandRejectedError) Error() string {
+	return fmt.Sprintf("command from %s rejected: %s", e.Cmd.Caller, e.Reason)
+}
+
+type commandContextKey int
+
+const (
+	correlationIDKey commandContextKey = iota
+	metadataKey
+)
+
+// WithCorrelationID sets the correlation ID of commands sent with ctx
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// WithCommandMetadata adds a key/value pair to commands sent with ctx
+func WithCommandMetadata(ctx context.Context, key, value string) context.Context {
+	metadata := map[string]string{}
+	if existing, ok := ctx.Value(metadataKey).(map[string]string); ok {
+		for k, v := range existing {
+			metadata[k] = v
+		}
+	}
+	metadata[key] = value
+	return context.WithValue(ctx, metadataKey, metadata)
+}
+
+// Use appends middleware to the chain around SendCommand. The first middleware added runs first.
+func (c *URCommon) Use(middleware ...Middleware) {
+	c.middlewareMu.Lock()
+	defer c.middlewareMu.Unlock()
+	c.middleware = append(c.middleware, middleware...)
+}
+
+func (c *URCommon) SendCommand(cmd string) error {
+	return c.send(c.Ctx, &Command{Text: cmd})
+}
+
+// SendCommandContext sends a command with the correlation ID and metadata set on ctx
+func (c *URCommon) SendCommandContext(ctx context.Context, cmd string) error {
+	return c.send(ctx, &Command{Text: cmd})
+}
+
+// send fills in the command metadata and runs it through the middleware chain
+func (c *URCommon) send(ctx context.Context, cmd *Command) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cmd.Time = time.Now()
+	cmd.Caller = commandCaller()
+	if id, ok := ctx.Value(correlationIDKey).(string); ok {
+		cmd.CorrelationID = id
+	} else {
+		cmd.CorrelationID = newCorrelationID()
+	}
+	cmd.Metadata = map[string]string{}
+	if metadata, ok := ctx.Value(metadataKey).(map[string]string); ok {
+		for k, v := range metadata {
+			cmd.Metadata[k] = v
+		}
+	}
+
+	c.middlewareMu.Lock()
+	handler := c.sink
+	if handler == nil {
+		handler = c.write
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		handler = c.middleware[i](handler)
+	}
+	c.middlewareMu.Unlock()
+
+	return handler(ctx, cmd)
+}
+
+// write is the end of the chain and writes the command to the connection
+func (c *URCommon) write(ctx context.Context, cmd *Command) error {
+	return c.writeBytes(ctx, []byte(cmd.Text+"\r\n"))
+}
+
+// LoggingMiddleware logs every command with its caller and correlation ID
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return func(ctx context.Context, cmd *Command) error {
+			err := next(ctx, cmd)
+			logger.Info("Sent command", "caller", cmd.Caller, "correlation_id", cmd.CorrelationID, "bytes", len(cmd.Text), "error", err)
+			return err
+		}
+	}
+}
+
+// PolicyMiddleware rejects commands for which check returns an error
+func PolicyMiddleware(check func(ctx context.Context, cmd *Command) error) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return func(ctx context.Context, cmd *Command) error {
+			if err := check(ctx, cmd); err != nil {
+				return &CommandRejectedError{Reason: err.Error(), Cmd: cmd}
+			}
+			return next(ctx, cmd)
+		}
+	}
+}
+
+// commandPlumbing are the functions between the caller and the middleware chain
+var commandPlumbing = []string{
+	"ur.(*URCommon).send",
+	"ur.(*URCommon).SendCommand",
+	"ur.(*URCommon).SendCommandContext",
+	"ur.(*URController).sendMoves",
+	"ur.(*URController).moveSequence",
+}
+
+// commandCaller returns the first function outside the send plumbing. The Context
+// suffix of this package's methods is dropped, so MoveJ and MoveJContext read the same.
+func commandCaller() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		name := frame.Function[strings.LastIndex(frame.Function, "/")+1:]
+
+		plumbing := false
+		for _, p := range commandPlumbing {
+			if name == p {
+				plumbing = true
+				break
+			}
+		}
+		if !plumbing || !more {
+			if strings.HasPrefix(name, "ur.") {
+				name = strings.TrimSuffix(name, "Context")
+			}
+			return name
+		}
+	}
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
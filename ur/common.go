@@ -5,58 +5,320 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"strconv"
+	"sync"
 	"time"
 )
 
 type URConfig struct {
 	IP      string
 	Port    int
-	Timeout time.Duration
+	Timeout time.Duration // used when the context has no deadline
+	Model   string        // e.g. "UR5e", see ModelNames. Connect fails for unknown names. Leave empty to auto-detect.
+	DryRun  bool          // controller only: record programs instead of sending them, see DryRunReport
 }
 
+// URCommon is a connection to one of the robot's interfaces. It is safe for concurrent use:
+// a reader goroutine owns all reads from the socket and writes are serialised.
 type URCommon struct {
-	Ctx  context.Context
-	conn net.Conn
+	Ctx context.Context
+
+	mu    sync.RWMutex
+	conn  *connection
+	model *URModel
+	audit *AuditLog
+
+	modelErr error // why URConfig.Model could not be resolved
+
+	auditInstalled bool // the audit middleware is in the chain
+
+	writeMu sync.Mutex
 
 	cfg URConfig
+
+	middlewareMu sync.Mutex
+	middleware   []Middleware
+	sink         CommandHandler // replaces writing to the connection, e.g. in dry-run mode
+
+	route func(cn *connection, data []byte) // takes the received bytes instead of read, e.g. to frame RTDE packages
+}
+
+// connection is an open socket and the reader goroutine that drains it
+type connection struct {
+	net.Conn
+	id     string      // identifies the connection in the audit log
+	reads  chan []byte // received chunks for read; the oldest are dropped when nobody reads
+	closed chan struct{}
+	once   sync.Once
+	err    error // why the connection closed, set before closed is closed
+}
+
+// close closes the socket once and records why
+func (cn *connection) close(reason error) error {
+	err := net.ErrClosed
+	cn.once.Do(func() {
+		cn.err = reason
+		close(cn.closed)
+		err = cn.Conn.Close()
+	})
+	return err
+}
+
+// queue hands data to read without blocking. When the queue is full the oldest chunk is
+// dropped, so the reader keeps draining the socket and notices a closed connection even
+// when nobody reads, e.g. the state the controller's interface streams.
+func (cn *connection) queue(data []byte) {
+	for {
+		select {
+		case cn.reads <- data:
+			return
+		default:
+		}
+
+		select {
+		case <-cn.reads:
+		default:
+		}
+	}
+}
+
+// Model returns the robot model selected in URConfig or set after detection.
+// It returns nil when the model is unknown.
+func (c *URCommon) Model() *URModel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resolveModel()
+	return c.model
+}
+
+// modelError returns why the model in URConfig is unknown, or nil
+func (c *URCommon) modelError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.resolveModel()
+}
+
+// resolveModel looks up the model in URConfig once and reports an unknown name once.
+// The caller holds mu.
+func (c *URCommon) resolveModel() error {
+	if c.model != nil || c.cfg.Model == "" || c.modelErr != nil {
+		return c.modelErr
+	}
+
+	model, err := LookupModel(c.cfg.Model)
+	if err != nil {
+		c.modelErr = fmt.Errorf("configured robot model: %v", err)
+		slog.Error("Unknown configured robot model, joint limits cannot be checked", "model", c.cfg.Model)
+		return c.modelErr
+	}
+	c.model = model
+	return nil
+}
+
+// SetModel overrides the robot model, e.g. after DetectModel
+func (c *URCommon) SetModel(model *URModel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.model = model
+	c.modelErr = nil
 }
 
 func (c *URCommon) Connect() error {
+	return c.ConnectContext(c.Ctx)
+}
+
+// ConnectContext dials the robot. URConfig.Timeout limits the dial unless ctx has an earlier deadline.
+// It fails without dialing when URConfig.Model names an unknown model.
+func (c *URCommon) ConnectContext(ctx context.Context) error {
+	if err := c.modelError(); err != nil {
+		return err
+	}
+
 	slog.Info("Connecting to robot...")
 
-	addr := fmt.Sprintf("%s:%d", c.cfg.IP, c.cfg.Port)
-	conn, err := net.DialTimeout("tcp", addr, c.cfg.Timeout)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	dialer := net.Dialer{Timeout: c.cfg.Timeout}
+	addr := net.JoinHostPort(c.cfg.IP, strconv.Itoa(c.cfg.Port))
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
+		c.recordAudit(AuditEntry{Kind: AUDIT_CONNECT, Address: addr, Error: err.Error()})
 		return err
 	}
 
-	c.conn = conn
+	cn := &connection{
+		Conn:   conn,
+		id:     newCorrelationID(),
+		reads:  make(chan []byte, 64),
+		closed: make(chan struct{}),
+	}
+
+	c.mu.Lock()
+	previous := c.conn
+	c.conn = cn
+	c.mu.Unlock()
+
+	if previous != nil {
+		previous.close(fmt.Errorf("replaced by a new connection"))
+	}
+	go c.readLoop(cn)
 
+	c.recordAudit(AuditEntry{Kind: AUDIT_CONNECT, Address: addr})
 	slog.Info("Connected to robot.")
 	return nil
 }
 
 func (c *URCommon) Disconnect() error {
 	slog.Info("Disconnecting from robot...")
-	if c.conn == nil {
+
+	c.mu.Lock()
+	cn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+
+	if cn == nil {
 		return nil
 	}
 
-	err := c.conn.Close()
+	err := cn.close(net.ErrClosed)
 	if err != nil {
 		return err
 	}
 
-	c.conn = nil
+	c.recordAuditFor(cn, AuditEntry{Kind: AUDIT_DISCONNECT})
 	slog.Info("Disconnected from robot.")
 	return nil
 }
 
 func (c *URCommon) IsConnected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.conn != nil
 }
 
-func (c *URCommon) SendCommand(cmd string) error {
-	_, err := c.conn.Write([]byte(cmd + "\r\n"))
+// connection returns the current connection, or an error when disconnected
+func (c *URCommon) connection() (*connection, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.conn == nil {
+		return nil, fmt.Errorf(ErrNotConnected)
+	}
+	return c.conn, nil
+}
+
+// readLoop is the only reader of the socket. It hands the received bytes to route, or
+// to read when no route is set, and marks the connection as lost when reading fails.
+func (c *URCommon) readLoop(cn *connection) {
+	for {
+		buf := make([]byte, 4096)
+		n, err := cn.Read(buf)
+		if n > 0 && c.route != nil {
+			c.route(cn, buf[:n])
+		} else if n > 0 {
+			cn.queue(buf[:n])
+		}
+		if err == nil {
+			continue
+		}
+
+		select {
+		case <-cn.closed:
+			// Closed by Disconnect or a new connection
+		default:
+			slog.Warn("Connection to robot lost", "error", err)
+			c.mu.Lock()
+			if c.conn == cn {
+				c.conn = nil
+			}
+			c.mu.Unlock()
+			cn.close(err)
+			c.recordAuditFor(cn, AuditEntry{Kind: AUDIT_DISCONNECT, Error: err.Error()})
+		}
+		return
+	}
+}
+
+// read returns the next bytes received from the robot. It waits until ctx is done, or
+// for URConfig.Timeout when ctx has no deadline.
+func (c *URCommon) read(ctx context.Context) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cn, err := c.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	var timeout <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok && c.cfg.Timeout > 0 {
+		timer := time.NewTimer(c.cfg.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	// Prefer data that has already arrived over a closed connection
+	select {
+	case data := <-cn.reads:
+		return data, nil
+	default:
+	}
+
+	select {
+	case data := <-cn.reads:
+		return data, nil
+	case <-cn.closed:
+		return nil, fmt.Errorf("connection to robot closed: %v", cn.err)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		return nil, fmt.Errorf("timeout reading from socket")
+	}
+}
+
+// writeBytes sends data to the robot. Writes from different goroutines never interleave.
+// The deadline of ctx is used, or URConfig.Timeout when ctx has none; cancelling ctx
+// interrupts a blocked write.
+func (c *URCommon) writeBytes(ctx context.Context, data []byte) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	cn, err := c.connection()
+	if err != nil {
+		return err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok && c.cfg.Timeout > 0 {
+		deadline = time.Now().Add(c.cfg.Timeout)
+	}
+	cn.SetWriteDeadline(deadline)
+	defer cn.SetWriteDeadline(time.Time{})
+
+	// Move the deadline into the past to unblock the write on cancellation
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		cn.SetWriteDeadline(time.Unix(1, 0))
+		close(interrupted)
+	})
+
+	_, err = cn.Write(data)
+	if !stop() {
+		// Wait for the interruption so it cannot leak into the next write
+		<-interrupted
+	}
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
 	return err
 }
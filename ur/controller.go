@@ -2,19 +2,69 @@ package ur
 
 import (
 	"context"
+	"log/slog"
 )
 
 type URController struct {
 	*URCommon
+	Store     *PositionStore // seeded with DefaultPositionMap
+	Frames    *Frames
+	Registers *RegisterManager
+
+	// Positions is the fixed cell layout DoWork used before the store.
+	//
+	// Deprecated: set the positions in Store instead. Positions changed from their
+	// initial values still take precedence over the store in DoWork.
 	Positions PositionMap
+	preset    PositionMap // Positions as seeded into Store
+
+	dryRun *dryRun
 }
 
 func NewController(ctx context.Context, cfg URConfig) *URController {
-	return &URController{
+	c := &URController{
 		URCommon: &URCommon{
 			Ctx: ctx,
 			cfg: cfg,
 		},
+		Frames:    NewFrames(),
+		Registers: NewRegisterManager(),
 		Positions: NewPositionMap(),
 	}
+	c.preset = c.Positions
+
+	store, err := c.preset.ToStore()
+	if err != nil {
+		slog.Warn("Ignoring default positions", "error", err)
+		store = NewPositionStore()
+	}
+	c.Store = store
+	if cfg.DryRun {
+		c.dryRun = &dryRun{}
+		c.sink = func(ctx context.Context, cmd *Command) error {
+			cmd.DryRun = true
+			c.dryRun.record(cmd.Caller, cmd.Text, cmd.Moves, c.Model())
+			return nil
+		}
+	}
+	return c
+}
+
+func (c *URController) Connect() error {
+	return c.ConnectContext(c.Ctx)
+}
+
+func (c *URController) ConnectContext(ctx context.Context) error {
+	if c.dryRun != nil {
+		if err := c.modelError(); err != nil {
+			return err
+		}
+		slog.Info("Dry run, not connecting to robot.")
+		return nil
+	}
+	return c.URCommon.ConnectContext(ctx)
+}
+
+func (c *URController) IsConnected() bool {
+	return c.dryRun != nil || c.URCommon.IsConnected()
 }
@@ -0,0 +1,194 @@
+package ur
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Dashboard server commands
+const (
+	DASHBOARD_ROBOT_MODEL       = "get robot model"
+	DASHBOARD_POLYSCOPE_VERSION = "PolyscopeVersion"
+)
+
+// versionPattern matches the version in e.g. "URSoftware 5.11.1.108318 (Mar 22 2021)"
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// URDashboard sends line commands to the dashboard server and reads their replies
+type URDashboard struct {
+	*URCommon
+
+	mu      sync.Mutex
+	pending []byte // received bytes after the last reply line
+}
+
+func NewDashboard(ctx context.Context, cfg URConfig) *URDashboard {
+	if cfg.Port == 0 {
+		cfg.Port = DASHBOARD_PORT
+	}
+	return &URDashboard{
+		URCommon: &URCommon{
+			Ctx: ctx,
+			cfg: cfg,
+		},
+	}
+}
+
+func (d *URDashboard) Connect() error {
+	return d.ConnectContext(d.Ctx)
+}
+
+// ConnectContext connects and reads the welcome line of the dashboard server
+func (d *URDashboard) ConnectContext(ctx context.Context) error {
+	err := d.URCommon.ConnectContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending = nil
+	if _, err := d.readLine(ctx); err != nil {
+		return fmt.Errorf("reading dashboard welcome: %v", err)
+	}
+	return nil
+}
+
+// Request sends a dashboard command and returns the reply line
+func (d *URDashboard) Request(cmd string) (string, error) {
+	return d.RequestContext(d.Ctx, cmd)
+}
+
+func (d *URDashboard) RequestContext(ctx context.Context, cmd string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.SendCommandContext(ctx, cmd); err != nil {
+		return "", err
+	}
+	reply, err := d.readLine(ctx)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(reply, "Could not understand") {
+		return "", fmt.Errorf("dashboard refused %q: %s", cmd, reply)
+	}
+	return reply, nil
+}
+
+// RobotModel returns the robot type, e.g. "UR5". Older software reports e-Series arms
+// without the e suffix.
+func (d *URDashboard) RobotModel() (string, error) {
+	return d.RobotModelContext(d.Ctx)
+}
+
+func (d *URDashboard) RobotModelContext(ctx context.Context) (string, error) {
+	return d.RequestContext(ctx, DASHBOARD_ROBOT_MODEL)
+}
+
+// PolyscopeVersion returns the version of the software running on the controller
+func (d *URDashboard) PolyscopeVersion() (ControlVersion, error) {
+	return d.PolyscopeVersionContext(d.Ctx)
+}
+
+func (d *URDashboard) PolyscopeVersionContext(ctx context.Context) (ControlVersion, error) {
+	reply, err := d.RequestContext(ctx, DASHBOARD_POLYSCOPE_VERSION)
+	if err != nil {
+		return ControlVersion{}, err
+	}
+	return parsePolyscopeVersion(reply)
+}
+
+// DetectModel asks for the robot type and software version and resolves the model
+func (d *URDashboard) DetectModel() (*URModel, error) {
+	return d.DetectModelContext(d.Ctx)
+}
+
+func (d *URDashboard) DetectModelContext(ctx context.Context) (*URModel, error) {
+	robotType, err := d.RobotModelContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	version, err := d.PolyscopeVersionContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return DetectModel(robotType, version)
+}
+
+// readLine returns the next reply line within ctx. The caller holds mu.
+func (d *URDashboard) readLine(ctx context.Context) (string, error) {
+	for {
+		if n := bytes.IndexByte(d.pending, '\n'); n >= 0 {
+			line := string(d.pending[:n])
+			d.pending = d.pending[n+1:]
+			return strings.TrimSpace(line), nil
+		}
+
+		data, err := d.read(ctx)
+		if err != nil {
+			return "", err
+		}
+		d.pending = append(d.pending, data...)
+	}
+}
+
+// parsePolyscopeVersion parses a reply like "URSoftware 5.11.1.108318 (Mar 22 2021)"
+func parsePolyscopeVersion(reply string) (ControlVersion, error) {
+	match := versionPattern.FindStringSubmatch(reply)
+	if match == nil {
+		return ControlVersion{}, fmt.Errorf("invalid PolyScope version: %q", reply)
+	}
+
+	var parts [4]uint32
+	for i, s := range match[1:] {
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return ControlVersion{}, fmt.Errorf("invalid PolyScope version: %q", reply)
+		}
+		parts[i] = uint32(n)
+	}
+	return ControlVersion{Major: parts[0], Minor: parts[1], Bugfix: parts[2], Build: parts[3]}, nil
+}
+
+// DetectModel asks the dashboard server for the robot type and software version and
+// sets the model of the controller. It opens its own dashboard connection.
+func (c *URController) DetectModel() (*URModel, error) {
+	return c.DetectModelContext(c.Ctx)
+}
+
+func (c *URController) DetectModelContext(ctx context.Context) (*URModel, error) {
+	cfg := c.cfg
+	cfg.Port = DASHBOARD_PORT
+	cfg.DryRun = false
+	cfg.Model = ""
+	d := NewDashboard(ctx, cfg)
+
+	c.mu.RLock()
+	audit := c.audit
+	c.mu.RUnlock()
+	if audit != nil {
+		d.SetAuditLog(audit)
+	}
+
+	if err := d.ConnectContext(ctx); err != nil {
+		return nil, err
+	}
+	defer d.Disconnect()
+
+	model, err := d.DetectModelContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.SetModel(model)
+	return model, nil
+}
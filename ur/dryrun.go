@@ -0,0 +1,182 @@
+package ur
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DryRunProgram is a program recorded instead of being sent to the robot
+type DryRunProgram struct {
+	Index      int
+	Source     string // function that sent the program, e.g. "ur.(*URController).DoWork"
+	Program    string
+	Moves      []MoveCmd
+	Violations []Violation
+	Estimate   *MotionEstimate // nil for programs without moves
+	Err        string          // validation or estimation error
+	Time       time.Time
+}
+
+// DryRunReport summarizes the programs recorded in dry-run mode
+type DryRunReport struct {
+	Programs   []DryRunProgram
+	Violations int
+	Errors     int
+	Duration   time.Duration // estimated motion time of the programs the robot would run
+	Rejected   time.Duration // estimated motion time of programs that failed validation
+}
+
+func (r *DryRunReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d program(s), %d violation(s), %d error(s), estimated motion time %v",
+		len(r.Programs), r.Violations, r.Errors, r.Duration)
+	if r.Rejected > 0 {
+		fmt.Fprintf(&b, " (%v in rejected programs)", r.Rejected)
+	}
+	b.WriteString("\n")
+	for _, p := range r.Programs {
+		fmt.Fprintf(&b, "[%d] %s", p.Index, p.Source)
+		if p.Estimate != nil {
+			fmt.Fprintf(&b, " %d move(s) %v", len(p.Moves), p.Estimate.Total)
+		}
+		b.WriteString("\n")
+		for _, v := range p.Violations {
+			fmt.Fprintf(&b, "    %s\n", v)
+		}
+		if p.Err != "" && len(p.Violations) == 0 {
+			fmt.Fprintf(&b, "    %s\n", p.Err)
+		}
+	}
+	return b.String()
+}
+
+// dryRun records programs and tracks where the robot would be
+type dryRun struct {
+	mu       sync.Mutex
+	programs []DryRunProgram
+	position *URPosition
+}
+
+// record stores a program with its moves, validation result and timing estimate
+func (d *dryRun) record(source, program string, moves []MoveCmd, model *URModel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p := DryRunProgram{
+		Index:   len(d.programs),
+		Source:  source,
+		Program: program,
+		Moves:   moves,
+		Time:    time.Now(),
+	}
+
+	if len(moves) > 0 {
+		if err := ValidateMoveCmds(moves, model); err != nil {
+			p.Err = err.Error()
+			var verr *ValidationError
+			if errors.As(err, &verr) {
+				p.Violations = verr.Violations
+			}
+		}
+
+		estimate, err := EstimateMoveCmds(moves, d.position, model)
+		switch {
+		case err != nil && p.Err == "":
+			p.Err = err.Error()
+		case err == nil:
+			p.Estimate = estimate
+			// The robot would have rejected an invalid program and stayed put
+			if p.Err == "" {
+				d.position = endPosition(moves, estimate)
+			}
+		}
+	}
+
+	slog.Info("Dry run: recorded program", "index", p.Index, "source", source, "moves", len(moves))
+	d.programs = append(d.programs, p)
+}
+
+func (d *dryRun) report() *DryRunReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	report := &DryRunReport{Programs: append([]DryRunProgram(nil), d.programs...)}
+	for _, p := range d.programs {
+		report.Violations += len(p.Violations)
+		if p.Err != "" {
+			report.Errors++
+		}
+		switch {
+		case p.Estimate == nil:
+		case p.Err != "":
+			// The robot would have rejected the program, so it adds no motion time
+			report.Rejected += p.Estimate.Total
+		default:
+			report.Duration += p.Estimate.Total
+		}
+	}
+	return report
+}
+
+// endPosition returns the joint position after the moves, or nil when it is unknown
+func endPosition(moves []MoveCmd, estimate *MotionEstimate) *URPosition {
+	if n := len(estimate.Segments); n > 0 {
+		pos := estimate.Segments[n-1].To
+		return &pos
+	}
+
+	last := moves[len(moves)-1]
+	if last.Offset != nil {
+		return nil
+	}
+	targets := last.targets()
+	pos := targets[len(targets)-1]
+	return &pos
+}
+
+// IsDryRun reports whether programs are recorded instead of sent to the robot
+func (c *URController) IsDryRun() bool {
+	return c.dryRun != nil
+}
+
+// DryRunReport returns the programs recorded so far, or nil outside dry-run mode
+func (c *URController) DryRunReport() *DryRunReport {
+	if c.dryRun == nil {
+		return nil
+	}
+	return c.dryRun.report()
+}
+
+// SetDryRunStart sets the joint position the rehearsal starts from, so the first
+// move is included in the timing estimate
+func (c *URController) SetDryRunStart(start URPosition) {
+	if c.dryRun == nil {
+		return
+	}
+	c.dryRun.mu.Lock()
+	defer c.dryRun.mu.Unlock()
+	c.dryRun.position = &start
+}
+
+// sendMoves validates the moves and sends the program. In dry-run mode invalid
+// programs are recorded too, and validation errors are returned in both modes.
+// Nothing is sent while the configured model is unknown.
+func (c *URController) sendMoves(ctx context.Context, program string, moves []MoveCmd) error {
+	if err := c.modelError(); err != nil {
+		return err
+	}
+
+	err := ValidateMoveCmds(moves, c.Model())
+	if err != nil && c.dryRun == nil {
+		return err
+	}
+	if sendErr := c.send(ctx, &Command{Text: program, Moves: moves}); sendErr != nil {
+		return sendErr
+	}
+	return err
+}
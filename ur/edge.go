@@ -0,0 +1,187 @@
+package ur
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Edge selects which transitions of a digital input trigger a callback
+type Edge int
+
+const (
+	EDGE_RISING Edge = iota
+	EDGE_FALLING
+	EDGE_BOTH
+)
+
+type EdgeOptions struct {
+	Debounce time.Duration // time a new value must be held before it counts
+	Timeout  time.Duration // WaitForDigitalInput only, 0 waits until ctx is done
+}
+
+type EdgeOption func(*EdgeOptions)
+
+func WithDebounce(d time.Duration) EdgeOption {
+	return func(opts *EdgeOptions) {
+		opts.Debounce = d
+	}
+}
+
+func WithTimeout(d time.Duration) EdgeOption {
+	return func(opts *EdgeOptions) {
+		opts.Timeout = d
+	}
+}
+
+// edgeListener tracks the debounced state of one input
+type edgeListener struct {
+	pin  DigitalPin
+	edge Edge
+	opts EdgeOptions
+	fn   func(IOEvent)
+
+	initialized  bool
+	stable       bool
+	pending      bool
+	pendingValue bool
+	pendingSince time.Time
+}
+
+// update feeds a new state to the listener and returns the event to fire, if any
+func (l *edgeListener) update(state IOState) (IOEvent, bool) {
+	value, _ := state.DigitalIn(l.pin)
+
+	if !l.initialized {
+		l.initialized = true
+		l.stable = value
+		return IOEvent{}, false
+	}
+
+	if value == l.stable {
+		l.pending = false
+		return IOEvent{}, false
+	}
+
+	if !l.pending || l.pendingValue != value {
+		l.pending = true
+		l.pendingValue = value
+		l.pendingSince = state.Timestamp
+	}
+	if state.Timestamp.Sub(l.pendingSince) < l.opts.Debounce {
+		return IOEvent{}, false
+	}
+
+	l.stable = value
+	l.pending = false
+
+	if l.edge == EDGE_BOTH || (l.edge == EDGE_RISING) == value {
+		return IOEvent{Pin: l.pin, Value: value, Time: state.Timestamp}, true
+	}
+	return IOEvent{}, false
+}
+
+// OnEdge calls fn when the digital input changes in the given direction, after debouncing.
+// The returned function removes the callback. Events are fired from Update, so Run must be active.
+func (io *URIO) OnEdge(pin DigitalPin, edge Edge, fn func(IOEvent), options ...EdgeOption) (func(), error) {
+	if _, err := pin.bit(); err != nil {
+		return nil, err
+	}
+	if edge < EDGE_RISING || edge > EDGE_BOTH {
+		return nil, fmt.Errorf("invalid edge: %d", edge)
+	}
+
+	opts := EdgeOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	l := &edgeListener{pin: pin, edge: edge, opts: opts, fn: fn}
+
+	io.mu.Lock()
+	defer io.mu.Unlock()
+
+	if io.state != nil {
+		l.update(*io.state)
+	}
+
+	id := io.nextListenerID
+	io.nextListenerID++
+	io.edges[id] = l
+
+	return func() {
+		io.mu.Lock()
+		defer io.mu.Unlock()
+		delete(io.edges, id)
+	}, nil
+}
+
+// WaitForDigitalInput blocks until the digital input is in the given state. A change must be
+// held for the debounce time to count. It reads the stream itself unless Run is active.
+func (io *URIO) WaitForDigitalInput(ctx context.Context, pin DigitalPin, value bool, options ...EdgeOption) error {
+	opts := EdgeOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	reached := make(chan struct{}, 1)
+	remove, err := io.OnEdge(pin, EDGE_BOTH, func(e IOEvent) {
+		if e.Value == value {
+			select {
+			case reached <- struct{}{}:
+			default:
+			}
+		}
+	}, options...)
+	if err != nil {
+		return err
+	}
+	defer remove()
+
+	// The input may already be in the wanted state
+	if state := io.State(); state != nil {
+		if current, _ := state.DigitalIn(pin); current == value {
+			return nil
+		}
+	}
+
+	for {
+		if io.isRunning() {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("waiting for %s to be %t: %v", pin, value, ctx.Err())
+			case <-reached:
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s to be %t: %v", pin, value, ctx.Err())
+		case <-reached:
+			return nil
+		default:
+		}
+
+		first := io.State() == nil
+		if _, err := io.UpdateContext(ctx); err != nil {
+			return err
+		}
+		if first {
+			if current, _ := io.State().DigitalIn(pin); current == value {
+				return nil
+			}
+		}
+	}
+}
+
+func (io *URIO) isRunning() bool {
+	io.mu.Lock()
+	defer io.mu.Unlock()
+	return io.running
+}
@@ -3,4 +3,12 @@ package ur
 const (
 	// ErrInvalidNumberOfJoints is returned when the number of joints is not 6
 	ErrInvalidNumberOfJoints = "invalid number of joints. Expected 6, got %d"
+	// ErrUnknownModel is returned when a robot model is not in the catalog
+	ErrUnknownModel = "unknown robot model: %q"
+	// ErrModelSeriesMismatch is returned when a model does not exist for the controller generation
+	ErrModelSeriesMismatch = "robot model %s does not match controller major version %d"
+	// ErrStatementDiscarded is returned when the interpreter refuses a statement
+	ErrStatementDiscarded = "statement discarded: %s: %q"
+	// ErrNotConnected is returned when using a connection before Connect
+	ErrNotConnected = "not connected to robot"
 )
@@ -0,0 +1,166 @@
+package ur
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// SegmentEstimate is the estimated duration of a single move
+type SegmentEstimate struct {
+	Cmd       int // index of the command in the program
+	Iteration int // loop iteration, 0 when the command does not loop
+	Type      string
+	From      URPosition
+	To        URPosition
+	Distance  float64 // leading axis angle (rad) for movej, tool distance (m) otherwise
+	Duration  time.Duration
+}
+
+// MotionEstimate is the estimated duration of a program
+type MotionEstimate struct {
+	Segments []SegmentEstimate
+	Total    time.Duration
+}
+
+// EstimateMoveCmds estimates how long the commands take to execute.
+// movej is modelled as a trapezoidal profile on the leading axis and movel/movep as
+// linear tool motion, which needs the model's kinematics. Blends are not modelled.
+// If start is nil the first target is assumed to be the current position.
+func EstimateMoveCmds(cmds []MoveCmd, start *URPosition, model *URModel) (*MotionEstimate, error) {
+	estimate := &MotionEstimate{}
+
+	var current *URPosition
+	if start != nil {
+		pos := *start
+		current = &pos
+	}
+
+	opts := defaultMoveOptions(model)
+	for i, cmd := range cmds {
+		cmd = cmd.withDefaults(opts)
+
+		iterations := 1
+		if cmd.Iterations > 0 && cmd.PosB != nil {
+			iterations = cmd.Iterations
+		}
+
+		for it := 0; it < iterations; it++ {
+			targets := cmd.targets()
+			if cmd.Offset != nil {
+				target, err := relativeJoints(cmd, current, model)
+				if err != nil {
+					return nil, fmt.Errorf("cmd[%d]: %v", i, err)
+				}
+				targets = []URPosition{target}
+			}
+
+			for _, target := range targets {
+				if current == nil {
+					pos := target
+					current = &pos
+					continue
+				}
+
+				segment, err := estimateSegment(cmd, *current, target, model)
+				if err != nil {
+					return nil, fmt.Errorf("cmd[%d]: %v", i, err)
+				}
+				segment.Cmd = i
+				if cmd.Iterations > 0 {
+					segment.Iteration = it
+				}
+
+				estimate.Segments = append(estimate.Segments, segment)
+				estimate.Total += segment.Duration
+
+				pos := target
+				current = &pos
+			}
+		}
+	}
+
+	return estimate, nil
+}
+
+// targets returns the positions visited by one pass of the command
+func (cmd MoveCmd) targets() []URPosition {
+	targets := []URPosition{cmd.PosA}
+	targets = append(targets, cmd.ViaPos...)
+	if cmd.PosB != nil {
+		targets = append(targets, *cmd.PosB)
+	}
+	return targets
+}
+
+// relativeJoints resolves the joint target of a relative move starting at current
+func relativeJoints(cmd MoveCmd, current *URPosition, model *URModel) (URPosition, error) {
+	if model == nil || current == nil {
+		return URPosition{}, fmt.Errorf("relative move estimation requires a robot model and a start position")
+	}
+
+	from := model.ForwardKinematicsPose(*current)
+	if cmd.From != nil {
+		from = *cmd.From
+	}
+
+	target, err := RelativeTarget(from, *cmd.Offset, cmd.OffsetFrame)
+	if err != nil {
+		return URPosition{}, err
+	}
+	return model.InverseKinematics(target, *current)
+}
+
+func estimateSegment(cmd MoveCmd, from, to URPosition, model *URModel) (SegmentEstimate, error) {
+	segment := SegmentEstimate{
+		Type: cmd.Type,
+		From: from,
+		To:   to,
+	}
+
+	switch cmd.Type {
+	case MOVE_J:
+		for j := range from {
+			segment.Distance = math.Max(segment.Distance, math.Abs(to[j]-from[j]))
+		}
+	case MOVE_L, MOVE_P:
+		if model == nil {
+			return segment, fmt.Errorf("%s estimation requires a robot model", cmd.Type)
+		}
+		a := model.ForwardKinematics(from).Translation()
+		b := model.ForwardKinematics(to).Translation()
+		segment.Distance = math.Sqrt(math.Pow(b[0]-a[0], 2) + math.Pow(b[1]-a[1], 2) + math.Pow(b[2]-a[2], 2))
+	default:
+		return segment, fmt.Errorf("unknown move type %q", cmd.Type)
+	}
+
+	seconds := trapezoidDuration(segment.Distance, cmd.Velocity, cmd.Acceleration)
+	segment.Duration = time.Duration(seconds * float64(time.Second))
+	return segment, nil
+}
+
+// trapezoidDuration returns the time to travel distance d starting and ending at rest
+// with peak velocity v and acceleration a. Short moves never reach v and become triangular.
+func trapezoidDuration(d, v, a float64) float64 {
+	if d <= 0 || v <= 0 || a <= 0 {
+		return 0
+	}
+	if d < v*v/a {
+		return 2 * math.Sqrt(d/a)
+	}
+	return d/v + v/a
+}
+
+// EstimateSequence estimates the duration of a program as MoveJSequence would send it
+func (c *URController) EstimateSequence(cmds []MoveCmd, start *URPosition) (*MotionEstimate, error) {
+	return EstimateMoveCmds(cmds, start, c.Model())
+}
+
+// EstimateWork estimates the duration of the DoWork program
+func (c *URController) EstimateWork(start *URPosition) (*MotionEstimate, error) {
+	cmds, err := c.workSequence()
+	if err != nil {
+		return nil, err
+	}
+	return c.EstimateSequence(cmds, start)
+}
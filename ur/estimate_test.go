@@ -0,0 +1,143 @@
+package ur
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTrapezoidDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		d, v, a float64
+		want    float64
+	}{
+		{"no distance", 0, 1, 1, 0},
+		{"no velocity", 1, 0, 1, 0},
+		{"no acceleration", 1, 1, 0, 0},
+		{"trapezoid", 2, 1, 1, 3},
+		{"triangle", 0.25, 1, 1, 1},
+		{"reaches v exactly", 1, 1, 1, 2},
+		{"fast acceleration", 3, 1.5, 150, 2.01},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trapezoidDuration(tt.d, tt.v, tt.a); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("trapezoidDuration(%v, %v, %v) = %v, want %v", tt.d, tt.v, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateMoveCmds(t *testing.T) {
+	ur5e, err := LookupModel("UR5e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Positions one radian apart on the base joint, so every movej segment takes
+	// 2 s at 1 rad/s and 1 rad/s^2
+	at := func(q float64) URPosition { return URPosition{q, -1.5, 1.5, -1.5, -1.5, 0} }
+	a, b, c := at(0), at(1), at(2)
+	start := a
+
+	tests := []struct {
+		name       string
+		cmds       []MoveCmd
+		start      *URPosition
+		model      *URModel
+		iterations []int // Iteration of each segment
+		total      time.Duration
+		wantErr    bool
+	}{
+		{
+			name:       "single move",
+			cmds:       []MoveCmd{{PosA: b, Type: MOVE_J, Velocity: 1, Acceleration: 1}},
+			start:      &start,
+			iterations: []int{0},
+			total:      2 * time.Second,
+		},
+		{
+			name:       "first target is the start without a start position",
+			cmds:       []MoveCmd{{PosA: a, Type: MOVE_J, Velocity: 1, Acceleration: 1}, {PosA: b, Type: MOVE_J, Velocity: 1, Acceleration: 1}},
+			iterations: []int{0},
+			total:      2 * time.Second,
+		},
+		{
+			name:       "loop expands into iterations",
+			cmds:       []MoveCmd{{PosA: b, PosB: &c, Iterations: 3, Type: MOVE_J, Velocity: 1, Acceleration: 1}},
+			start:      &start,
+			iterations: []int{0, 0, 1, 1, 2, 2},
+			total:      12 * time.Second,
+		},
+		{
+			name:       "iterations without PosB run once",
+			cmds:       []MoveCmd{{PosA: b, Iterations: 3, Type: MOVE_J, Velocity: 1, Acceleration: 1}},
+			start:      &start,
+			iterations: []int{0},
+			total:      2 * time.Second,
+		},
+		{
+			name:       "via points",
+			cmds:       []MoveCmd{{PosA: b, ViaPos: []URPosition{c, b}, Type: MOVE_J, Velocity: 1, Acceleration: 1}},
+			start:      &start,
+			iterations: []int{0, 0, 0},
+			total:      6 * time.Second,
+		},
+		{
+			name:       "movel with a model",
+			cmds:       []MoveCmd{{PosA: b, Type: MOVE_L}},
+			start:      &start,
+			model:      ur5e,
+			iterations: []int{0},
+		},
+		{
+			name:    "movel without a model",
+			cmds:    []MoveCmd{{PosA: b, Type: MOVE_L}},
+			start:   &start,
+			wantErr: true,
+		},
+		{
+			name:    "relative move without a model",
+			cmds:    []MoveCmd{NewRelativeMoveCmd(URPose{0, 0, 0.1, 0, 0, 0}, RELATIVE_BASE)},
+			start:   &start,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, err := EstimateMoveCmds(tt.cmds, tt.start, tt.model)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("EstimateMoveCmds() returned no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(estimate.Segments) != len(tt.iterations) {
+				t.Fatalf("EstimateMoveCmds() has %d segment(s), want %d", len(estimate.Segments), len(tt.iterations))
+			}
+			var sum time.Duration
+			for i, s := range estimate.Segments {
+				if s.Iteration != tt.iterations[i] {
+					t.Errorf("segment %d iteration = %d, want %d", i, s.Iteration, tt.iterations[i])
+				}
+				if s.Duration <= 0 {
+					t.Errorf("segment %d duration = %v, want positive", i, s.Duration)
+				}
+				sum += s.Duration
+			}
+			if estimate.Total != sum {
+				t.Errorf("Total = %v, want the segment sum %v", estimate.Total, sum)
+			}
+			if tt.total > 0 && estimate.Total != tt.total {
+				t.Errorf("Total = %v, want %v", estimate.Total, tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package ur
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Force mode types, see force_mode in the URScript manual
+const (
+	FORCE_MODE_TYPE_POINT  = 1 // force frame y-axis points from the TCP towards the task frame origin
+	FORCE_MODE_TYPE_FRAME  = 2 // force frame is the task frame
+	FORCE_MODE_TYPE_MOTION = 3 // force frame x-axis is the TCP velocity projected onto the task frame x-y plane
+)
+
+// RTDE recipe presets
+const (
+	RECIPE_TCP_FORCE = "actual_TCP_force"
+)
+
+// ForceMode holds the arguments of URScript's force_mode
+type ForceMode struct {
+	TaskFrame URPose
+	Selection [6]bool    // compliant axes of the task frame
+	Wrench    [6]float64 // N and Nm on compliant axes, ignored on the others
+	Type      int
+	// Limits are the max TCP speeds (m/s, rad/s) on compliant axes and the
+	// max deviations from the program path (m, rad) on the others
+	Limits [6]float64
+}
+
+// Validate checks the force mode arguments
+func (f ForceMode) Validate() error {
+	var problems []string
+
+	if f.Type < FORCE_MODE_TYPE_POINT || f.Type > FORCE_MODE_TYPE_MOTION {
+		problems = append(problems, fmt.Sprintf("invalid type %d", f.Type))
+	}
+	for i, l := range f.Limits {
+		if l <= 0 || math.IsNaN(l) {
+			problems = append(problems, fmt.Sprintf("limit %d must be positive, got %f", i, l))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid force mode: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (f ForceMode) String() string {
+	selection := make([]string, 6)
+	for i, s := range f.Selection {
+		selection[i] = "0"
+		if s {
+			selection[i] = "1"
+		}
+	}
+	return fmt.Sprintf("force_mode(%s, [%s], %s, %d, %s)",
+		f.TaskFrame.String(),
+		strings.Join(selection, ", "),
+		floatArrayToString(f.Wrench[:]),
+		f.Type,
+		floatArrayToString(f.Limits[:]),
+	)
+}
+
+// ForceMode appends a force_mode statement
+func (s *URScript) ForceMode(f ForceMode) *URScript {
+	return s.Add(f.String())
+}
+
+// EndForceMode appends an end_force_mode statement
+func (s *URScript) EndForceMode() *URScript {
+	return s.Add("end_force_mode()")
+}
+
+// ForceModeSetDamping appends a force_mode_set_damping statement. Damping is in [0, 1].
+func (s *URScript) ForceModeSetDamping(damping float64) *URScript {
+	return s.Addf("force_mode_set_damping(%f)", damping)
+}
+
+// ForceModeSetGainScaling appends a force_mode_set_gain_scaling statement. Scaling is in [0, 2].
+func (s *URScript) ForceModeSetGainScaling(scaling float64) *URScript {
+	return s.Addf("force_mode_set_gain_scaling(%f)", scaling)
+}
+
+// ZeroFTSensor appends a zero_ftsensor statement
+func (s *URScript) ZeroFTSensor() *URScript {
+	return s.Add("zero_ftsensor()")
+}
+
+type ForceModeOptions struct {
+	Damping     *float64 // force_mode_set_damping, in [0, 1]
+	GainScaling *float64 // force_mode_set_gain_scaling, in [0, 2]
+}
+
+type ForceModeOption func(*ForceModeOptions)
+
+func WithDamping(damping float64) ForceModeOption {
+	return func(opts *ForceModeOptions) {
+		opts.Damping = &damping
+	}
+}
+
+func WithGainScaling(scaling float64) ForceModeOption {
+	return func(opts *ForceModeOptions) {
+		opts.GainScaling = &scaling
+	}
+}
+
+// ForceModeProgram returns a program that runs body under force mode. Force mode only
+// lasts while its program runs, so body must contain the motion or waiting to do.
+func ForceModeProgram(f ForceMode, body *URScript, options ...ForceModeOption) (*URScript, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := ForceModeOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	program := NewURScript("force_control")
+	if opts.Damping != nil {
+		if *opts.Damping < 0 || *opts.Damping > 1 {
+			return nil, fmt.Errorf("force mode damping must be in [0, 1], got %f", *opts.Damping)
+		}
+		program.ForceModeSetDamping(*opts.Damping)
+	}
+	if opts.GainScaling != nil {
+		if *opts.GainScaling < 0 || *opts.GainScaling > 2 {
+			return nil, fmt.Errorf("force mode gain scaling must be in [0, 2], got %f", *opts.GainScaling)
+		}
+		program.ForceModeSetGainScaling(*opts.GainScaling)
+	}
+
+	program.ForceMode(f)
+	program.Add(body.Lines()...)
+	program.EndForceMode()
+	return program, nil
+}
+
+// RunForceMode sends a program that runs body under force mode and ends force mode
+// when body is done. It replaces the running program.
+func (c *URController) RunForceMode(f ForceMode, body *URScript, options ...ForceModeOption) error {
+	return c.RunForceModeContext(c.Ctx, f, body, options...)
+}
+
+func (c *URController) RunForceModeContext(ctx context.Context, f ForceMode, body *URScript, options ...ForceModeOption) error {
+	program, err := ForceModeProgram(f, body, options...)
+	if err != nil {
+		return err
+	}
+	return c.SendCommandContext(ctx, program.String())
+}
+
+func (c *URController) ZeroFTSensor() error {
+	return c.ZeroFTSensorContext(c.Ctx)
+}
+
+func (c *URController) ZeroFTSensorContext(ctx context.Context) error {
+	return c.SendCommandContext(ctx, "zero_ftsensor()")
+}
+
+// SetupForceOutput configures the RTDE output recipe to stream actual_TCP_force
+func (r *URReceiver) SetupForceOutput() (*OutputResponse, error) {
+	return r.SetupForceOutputContext(r.Ctx)
+}
+
+func (r *URReceiver) SetupForceOutputContext(ctx context.Context) (*OutputResponse, error) {
+	return r.SendOutputSetupContext(ctx, RECIPE_TCP_FORCE)
+}
+
+// WaitForForce reads the actual_TCP_force recipe until the magnitude of the force
+// exceeds threshold (N) and returns the wrench at that moment. recipeID is the ID
+// returned by SetupForceOutput; call StartDataExchange first.
+func (r *URReceiver) WaitForForce(ctx context.Context, recipeID uint8, threshold float64) ([6]float64, error) {
+	var wrench [6]float64
+	for {
+		select {
+		case <-ctx.Done():
+			return wrench, ctx.Err()
+		default:
+		}
+
+		data, err := r.ListenRecipeContext(ctx, recipeID)
+		if err != nil {
+			return wrench, err
+		}
+		if len(data) < 6*8 {
+			return wrench, fmt.Errorf("invalid data length for %s", RECIPE_TCP_FORCE)
+		}
+
+		values, _ := UnpackField(data, 0, TYPE_VECTOR_6D)
+		copy(wrench[:], values.([]float64))
+
+		if math.Sqrt(wrench[0]*wrench[0]+wrench[1]*wrench[1]+wrench[2]*wrench[2]) >= threshold {
+			return wrench, nil
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package ur
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	FRAME_BASE  = "base"
+	TOOL_FLANGE = "flange"
+)
+
+// Frame is a named reference frame, e.g. a user plane on a fixture.
+// Its pose is expressed in the parent frame.
+type Frame struct {
+	Name   string
+	Parent string
+	Pose   URPose
+}
+
+// Waypoint is a TCP pose expressed in a reference frame, using a named tool
+type Waypoint struct {
+	Frame string // defaults to the base frame
+	Tool  string // defaults to the flange
+	Pose  URPose
+}
+
+// Frames holds the user and tool frames of a cell. Moving a fixture only
+// requires updating its frame; waypoints defined in it follow along.
+type Frames struct {
+	frames map[string]Frame
+	tools  map[string]URPose
+}
+
+func NewFrames() *Frames {
+	return &Frames{
+		frames: make(map[string]Frame),
+		tools:  make(map[string]URPose),
+	}
+}
+
+// Set defines or updates a frame relative to its parent. An empty parent means the base frame.
+func (f *Frames) Set(name, parent string, pose URPose) error {
+	if name == "" || name == FRAME_BASE {
+		return fmt.Errorf("invalid frame name: %q", name)
+	}
+	if parent == "" {
+		parent = FRAME_BASE
+	}
+
+	previous, existed := f.frames[name]
+	f.frames[name] = Frame{Name: name, Parent: parent, Pose: pose}
+
+	if _, err := f.InBase(name); err != nil {
+		if existed {
+			f.frames[name] = previous
+		} else {
+			delete(f.frames, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Get returns the frame with the given name
+func (f *Frames) Get(name string) (Frame, bool) {
+	frame, ok := f.frames[name]
+	return frame, ok
+}
+
+// Names returns the names of all user frames, sorted
+func (f *Frames) Names() []string {
+	names := make([]string, 0, len(f.frames))
+	for name := range f.frames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetTool defines a tool (TCP) frame relative to the flange
+func (f *Frames) SetTool(name string, tcp URPose) error {
+	if name == "" || name == TOOL_FLANGE {
+		return fmt.Errorf("invalid tool name: %q", name)
+	}
+	f.tools[name] = tcp
+	return nil
+}
+
+// Tool returns the TCP offset of a tool relative to the flange
+func (f *Frames) Tool(name string) (URPose, error) {
+	if name == "" || name == TOOL_FLANGE {
+		return URPose{}, nil
+	}
+	tcp, ok := f.tools[name]
+	if !ok {
+		return URPose{}, fmt.Errorf("unknown tool: %q", name)
+	}
+	return tcp, nil
+}
+
+// InBase returns the pose of a frame expressed in the base frame
+func (f *Frames) InBase(name string) (URPose, error) {
+	pose := URPose{}
+	visited := make(map[string]bool)
+
+	for name != "" && name != FRAME_BASE {
+		if visited[name] {
+			return URPose{}, fmt.Errorf("cycle in frame %q", name)
+		}
+		visited[name] = true
+
+		frame, ok := f.frames[name]
+		if !ok {
+			return URPose{}, fmt.Errorf("unknown frame: %q", name)
+		}
+		pose = PoseTrans(frame.Pose, pose)
+		name = frame.Parent
+	}
+
+	return pose, nil
+}
+
+// ToBase converts a waypoint to the TCP pose in the base frame
+func (f *Frames) ToBase(wp Waypoint) (URPose, error) {
+	frame, err := f.InBase(wp.Frame)
+	if err != nil {
+		return URPose{}, err
+	}
+	return PoseTrans(frame, wp.Pose), nil
+}
+
+// FlangeInBase converts a waypoint to the flange pose in the base frame,
+// removing the tool offset
+func (f *Frames) FlangeInBase(wp Waypoint) (URPose, error) {
+	tcp, err := f.ToBase(wp)
+	if err != nil {
+		return URPose{}, err
+	}
+	tool, err := f.Tool(wp.Tool)
+	if err != nil {
+		return URPose{}, err
+	}
+	return PoseTrans(tcp, PoseInv(tool)), nil
+}
+
+// ToJoints converts a waypoint to joint positions with the model's inverse kinematics,
+// choosing the solution closest to seed
+func (f *Frames) ToJoints(wp Waypoint, model *URModel, seed URPosition) (URPosition, error) {
+	if model == nil {
+		return URPosition{}, fmt.Errorf("converting waypoints to joints requires a robot model")
+	}
+	flange, err := f.FlangeInBase(wp)
+	if err != nil {
+		return URPosition{}, err
+	}
+	return model.InverseKinematics(flange, seed)
+}
+
+// WaypointJoints converts a waypoint to joint positions for the controller's model
+func (c *URController) WaypointJoints(wp Waypoint, seed URPosition) (URPosition, error) {
+	return c.Frames.ToJoints(wp, c.Model(), seed)
+}
@@ -0,0 +1,236 @@
+package ur
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Handshake status values written by the robot
+const (
+	HANDSHAKE_READY = 0
+	HANDSHAKE_BUSY  = 1
+	HANDSHAKE_DONE  = 2
+	HANDSHAKE_ERROR = 3
+)
+
+// HANDSHAKE_TIMEOUT is returned by the URScript wait helper when no command arrives in time
+const HANDSHAKE_TIMEOUT = -1
+
+const DEFAULT_HANDSHAKE_TIMEOUT = 10 * time.Second
+
+// HandshakeError is returned when the robot program reports an error for a request
+type HandshakeError struct {
+	Command int32
+	Code    float64
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("robot failed command %d with code %g", e.Command, e.Code)
+}
+
+// Handshake is a request/acknowledge protocol between Go and a robot program over registers.
+//
+// Go writes the parameter and command and increments the sequence counter. The robot
+// program sees the new sequence, sets the status to busy, runs the command and then
+// writes the result, sets the status to done or error and echoes the sequence in the
+// acknowledge register. A request is complete once the acknowledge matches.
+type Handshake struct {
+	Name     string
+	receiver *URReceiver
+	timeout  time.Duration
+
+	command   Register
+	parameter Register
+	sequence  Register
+	status    Register
+	ack       Register
+	result    Register
+
+	mu      sync.Mutex
+	seq     int32
+	inputs  *DataConfig
+	outputs uint8 // output recipe ID
+}
+
+// NewHandshake allocates the handshake registers. Name prefixes the URScript helpers and
+// must be a valid URScript identifier.
+func NewHandshake(name string, regs *RegisterManager, receiver *URReceiver) (*Handshake, error) {
+	if !scriptName.MatchString(name) {
+		return nil, fmt.Errorf("invalid handshake name: %q", name)
+	}
+
+	h := &Handshake{
+		Name:     name,
+		receiver: receiver,
+		timeout:  DEFAULT_HANDSHAKE_TIMEOUT,
+		// The registers keep their value between runs, so start away from the last sequence
+		seq: int32(time.Now().Unix() % 100000),
+	}
+
+	allocations := []struct {
+		reg  *Register
+		name string
+		typ  RegisterType
+		dir  RegisterDirection
+	}{
+		{&h.command, "command", REGISTER_INT, REGISTER_INPUT},
+		{&h.parameter, "parameter", REGISTER_DOUBLE, REGISTER_INPUT},
+		{&h.sequence, "sequence", REGISTER_INT, REGISTER_INPUT},
+		{&h.status, "status", REGISTER_INT, REGISTER_OUTPUT},
+		{&h.ack, "ack", REGISTER_INT, REGISTER_OUTPUT},
+		{&h.result, "result", REGISTER_DOUBLE, REGISTER_OUTPUT},
+	}
+	for _, a := range allocations {
+		reg, err := regs.Allocate(name, a.name, a.typ, a.dir)
+		if err != nil {
+			regs.Release(name)
+			return nil, err
+		}
+		*a.reg = reg
+	}
+
+	return h, nil
+}
+
+// SetTimeout sets how long Request waits for the robot when ctx has no deadline
+func (h *Handshake) SetTimeout(d time.Duration) {
+	h.timeout = d
+}
+
+// InputRecipe returns the RTDE input recipe written by Go
+func (h *Handshake) InputRecipe() string {
+	return strings.Join([]string{h.command.Variable(), h.parameter.Variable(), h.sequence.Variable()}, ",")
+}
+
+// OutputRecipe returns the RTDE output recipe read by Go
+func (h *Handshake) OutputRecipe() string {
+	return strings.Join([]string{h.status.Variable(), h.ack.Variable(), h.result.Variable()}, ",")
+}
+
+// Setup configures the RTDE recipes. Call it before StartDataExchange on the receiver.
+func (h *Handshake) Setup() error {
+	return h.SetupContext(h.receiver.Ctx)
+}
+
+func (h *Handshake) SetupContext(ctx context.Context) error {
+	resp, err := h.receiver.SendOutputSetupContext(ctx, h.OutputRecipe())
+	if err != nil {
+		return err
+	}
+	if strings.Contains(resp.Types, "NOT_FOUND") || strings.Contains(resp.Types, "IN_USE") {
+		return fmt.Errorf("output setup rejected for %q: %s", h.OutputRecipe(), resp.Types)
+	}
+	h.outputs = resp.RecipeID
+
+	inputs, err := h.receiver.SendInputSetupContext(ctx, h.InputRecipe())
+	if err != nil {
+		return err
+	}
+	h.inputs = inputs
+	return nil
+}
+
+// Script returns the URScript helpers to inject into the robot program:
+//
+//	cmd = <name>_wait_command(timeout)  # blocks for the next request, HANDSHAKE_TIMEOUT on timeout
+//	p = <name>_parameter()
+//	<name>_done(result)
+//	<name>_fail(code)
+func (h *Handshake) Script() string {
+	n := h.Name
+	return strings.Join([]string{
+		fmt.Sprintf("global %s_seq = %s", n, h.sequence.ReadScript()),
+		fmt.Sprintf("write_output_integer_register(%d, %d)", h.status.Index, HANDSHAKE_READY),
+		fmt.Sprintf("write_output_integer_register(%d, %s_seq)", h.ack.Index, n),
+		fmt.Sprintf("def %s_wait_command(timeout):", n),
+		"  waited = 0",
+		fmt.Sprintf("  while %s == %s_seq:", h.sequence.ReadScript(), n),
+		"    if timeout > 0 and waited >= timeout:",
+		fmt.Sprintf("      return %d", HANDSHAKE_TIMEOUT),
+		"    end",
+		"    sync()",
+		"    waited = waited + get_steptime()",
+		"  end",
+		fmt.Sprintf("  %s_seq = %s", n, h.sequence.ReadScript()),
+		fmt.Sprintf("  write_output_integer_register(%d, %d)", h.status.Index, HANDSHAKE_BUSY),
+		fmt.Sprintf("  return %s", h.command.ReadScript()),
+		"end",
+		fmt.Sprintf("def %s_parameter():", n),
+		fmt.Sprintf("  return %s", h.parameter.ReadScript()),
+		"end",
+		fmt.Sprintf("def %s_done(result):", n),
+		fmt.Sprintf("  write_output_float_register(%d, result)", h.result.Index),
+		fmt.Sprintf("  write_output_integer_register(%d, %d)", h.status.Index, HANDSHAKE_DONE),
+		fmt.Sprintf("  write_output_integer_register(%d, %s_seq)", h.ack.Index, n),
+		"end",
+		fmt.Sprintf("def %s_fail(code):", n),
+		fmt.Sprintf("  write_output_float_register(%d, code)", h.result.Index),
+		fmt.Sprintf("  write_output_integer_register(%d, %d)", h.status.Index, HANDSHAKE_ERROR),
+		fmt.Sprintf("  write_output_integer_register(%d, %s_seq)", h.ack.Index, n),
+		"end",
+	}, "\n")
+}
+
+// Request sends a command with a parameter and waits until the robot acknowledges it.
+// It returns the result written by <name>_done, or a HandshakeError for <name>_fail.
+func (h *Handshake) Request(ctx context.Context, command int32, parameter float64) (float64, error) {
+	if h.inputs == nil {
+		return 0, fmt.Errorf("handshake %s is not set up", h.Name)
+	}
+	if _, ok := ctx.Deadline(); !ok && h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	seq := h.seq
+
+	err := h.receiver.SendInputsContext(ctx, h.inputs, &DataObject{Fields: map[string]interface{}{
+		h.command.Variable():   command,
+		h.parameter.Variable(): parameter,
+		h.sequence.Variable():  seq,
+	}})
+	if err != nil {
+		return 0, err
+	}
+
+	status := int32(HANDSHAKE_READY)
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("handshake %s: command %d (seq %d) not acknowledged, robot status %d: %v", h.Name, command, seq, status, ctx.Err())
+		default:
+		}
+
+		data, err := h.receiver.ListenRecipeContext(ctx, h.outputs)
+		if err != nil {
+			return 0, err
+		}
+		if len(data) < 4+4+8 {
+			return 0, fmt.Errorf("invalid data length for handshake %s", h.Name)
+		}
+
+		s, offset := UnpackField(data, 0, "INT32")
+		ack, offset := UnpackField(data, offset, "INT32")
+		result, _ := UnpackField(data, offset, TYPE_DOUBLE)
+		status = s.(int32)
+
+		if ack.(int32) != seq {
+			continue
+		}
+
+		switch status {
+		case HANDSHAKE_DONE:
+			return result.(float64), nil
+		case HANDSHAKE_ERROR:
+			return 0, &HandshakeError{Command: command, Code: result.(float64)}
+		}
+	}
+}
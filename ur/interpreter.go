@@ -0,0 +1,328 @@
+package ur
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const INTERPRETER_PORT = 30020
+
+// Interpreter commands that are not URScript statements
+const (
+	INTERPRETER_SKIP_BUFFER          = "skipbuffer"
+	INTERPRETER_ABORT                = "abort"
+	INTERPRETER_CLEAR                = "clear_interpreter()"
+	INTERPRETER_END                  = "end_interpreter()"
+	INTERPRETER_STATE_LAST_EXECUTED  = "statelastexecuted"
+	INTERPRETER_STATE_LAST_INTERPRET = "statelastinterpreted"
+	INTERPRETER_STATE_LAST_CLEARED   = "statelastcleared"
+	INTERPRETER_STATE_UNEXECUTED     = "stateunexecuted"
+)
+
+// DEFAULT_INTERPRETER_BUFFER is how many interpreted statements are kept before the
+// interpreter is cleared. The controller refuses new statements when it runs out of memory.
+const DEFAULT_INTERPRETER_BUFFER = 5000
+
+// URInterpreter sends single URScript statements to a program running in interpreter mode.
+// Start the mode with URController.StartInterpreterMode first.
+type URInterpreter struct {
+	*URCommon
+	pending []byte // received bytes after the last reply line
+
+	mu          sync.Mutex
+	interpreted int // statements interpreted since the last clear
+	MaxBuffer   int
+}
+
+func NewInterpreter(ctx context.Context, cfg URConfig) *URInterpreter {
+	if cfg.Port == 0 {
+		cfg.Port = INTERPRETER_PORT
+	}
+	return &URInterpreter{
+		URCommon: &URCommon{
+			Ctx: ctx,
+			cfg: cfg,
+		},
+		MaxBuffer: DEFAULT_INTERPRETER_BUFFER,
+	}
+}
+
+func (i *URInterpreter) Connect() error {
+	return i.ConnectContext(i.Ctx)
+}
+
+func (i *URInterpreter) ConnectContext(ctx context.Context) error {
+	err := i.URCommon.ConnectContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	i.pending = nil
+	i.mu.Unlock()
+	return nil
+}
+
+// Execute sends a single-line statement and returns the ID the interpreter assigned to it.
+// The statement has been interpreted, not executed, when Execute returns.
+func (i *URInterpreter) Execute(stmt string) (int, error) {
+	return i.ExecuteContext(i.Ctx, stmt)
+}
+
+func (i *URInterpreter) ExecuteContext(ctx context.Context, stmt string) (int, error) {
+	stmt = strings.TrimSpace(stmt)
+	if stmt == "" || strings.ContainsAny(stmt, "\r\n") {
+		return 0, fmt.Errorf("interpreter statements must be a single line: %q", stmt)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.MaxBuffer > 0 && i.interpreted >= i.MaxBuffer {
+		slog.Info("Interpreter buffer full, clearing", "interpreted", i.interpreted)
+		if _, err := i.request(ctx, INTERPRETER_CLEAR); err != nil {
+			return 0, err
+		}
+		i.interpreted = 0
+	}
+
+	reply, err := i.request(ctx, stmt)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := parseInterpreterAck(reply, stmt)
+	if err != nil {
+		return 0, err
+	}
+	i.interpreted++
+	return id, nil
+}
+
+// ExecuteScript executes the statements of a script one by one and returns their IDs.
+// Compound statements such as if or while must be written on a single line.
+func (i *URInterpreter) ExecuteScript(s *URScript) ([]int, error) {
+	return i.ExecuteScriptContext(i.Ctx, s)
+}
+
+func (i *URInterpreter) ExecuteScriptContext(ctx context.Context, s *URScript) ([]int, error) {
+	var ids []int
+	for _, line := range s.Lines() {
+		id, err := i.ExecuteContext(ctx, line)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// SkipBuffer skips the statements that have been interpreted but not executed yet
+func (i *URInterpreter) SkipBuffer() error {
+	return i.SkipBufferContext(i.Ctx)
+}
+
+func (i *URInterpreter) SkipBufferContext(ctx context.Context) error {
+	return i.command(ctx, INTERPRETER_SKIP_BUFFER)
+}
+
+// Abort stops the statement that is currently executing, e.g. a move
+func (i *URInterpreter) Abort() error {
+	return i.AbortContext(i.Ctx)
+}
+
+func (i *URInterpreter) AbortContext(ctx context.Context) error {
+	return i.command(ctx, INTERPRETER_ABORT)
+}
+
+// Clear removes interpreted statements from memory. Executed statements can no longer be referred to.
+func (i *URInterpreter) Clear() error {
+	return i.ClearContext(i.Ctx)
+}
+
+func (i *URInterpreter) ClearContext(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	reply, err := i.request(ctx, INTERPRETER_CLEAR)
+	if err != nil {
+		return err
+	}
+	if _, err := parseInterpreterAck(reply, INTERPRETER_CLEAR); err != nil {
+		return err
+	}
+	i.interpreted = 0
+	return nil
+}
+
+// End leaves interpreter mode. The robot program continues after interpreter_mode().
+func (i *URInterpreter) End() error {
+	return i.EndContext(i.Ctx)
+}
+
+func (i *URInterpreter) EndContext(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	reply, err := i.request(ctx, INTERPRETER_END)
+	if err != nil {
+		return err
+	}
+	_, err = parseInterpreterAck(reply, INTERPRETER_END)
+	return err
+}
+
+// LastExecuted returns the ID of the last statement that started executing
+func (i *URInterpreter) LastExecuted() (int, error) {
+	return i.state(i.Ctx, INTERPRETER_STATE_LAST_EXECUTED)
+}
+
+// LastInterpreted returns the ID of the last interpreted statement
+func (i *URInterpreter) LastInterpreted() (int, error) {
+	return i.state(i.Ctx, INTERPRETER_STATE_LAST_INTERPRET)
+}
+
+// LastCleared returns the ID of the last statement removed by Clear
+func (i *URInterpreter) LastCleared() (int, error) {
+	return i.state(i.Ctx, INTERPRETER_STATE_LAST_CLEARED)
+}
+
+// Unexecuted returns the number of interpreted statements waiting to execute
+func (i *URInterpreter) Unexecuted() (int, error) {
+	return i.state(i.Ctx, INTERPRETER_STATE_UNEXECUTED)
+}
+
+// WaitForExecuted blocks until the statement with the given ID has started executing
+func (i *URInterpreter) WaitForExecuted(ctx context.Context, id int, interval time.Duration) error {
+	for {
+		last, err := i.state(ctx, INTERPRETER_STATE_LAST_EXECUTED)
+		if err != nil {
+			return err
+		}
+		if last >= id {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for statement %d, last executed %d: %v", id, last, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// WaitForBuffer blocks until at most max statements are waiting to execute
+func (i *URInterpreter) WaitForBuffer(ctx context.Context, max int, interval time.Duration) error {
+	for {
+		n, err := i.state(ctx, INTERPRETER_STATE_UNEXECUTED)
+		if err != nil {
+			return err
+		}
+		if n <= max {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for interpreter buffer, %d unexecuted: %v", n, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// command sends an interpreter command that replies with its name
+func (i *URInterpreter) command(ctx context.Context, cmd string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	reply, err := i.request(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(reply, "discard") {
+		return fmt.Errorf(ErrStatementDiscarded, strings.TrimPrefix(reply, "discard: "), cmd)
+	}
+	return nil
+}
+
+// state sends a state query and parses the number in the reply, e.g. "statelastexecuted: 12"
+func (i *URInterpreter) state(ctx context.Context, query string) (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	reply, err := i.request(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	_, value, ok := strings.Cut(reply, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid reply to %s: %q", query, reply)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid reply to %s: %q", query, reply)
+	}
+	return n, nil
+}
+
+// request writes a line and reads the reply line within ctx. The caller holds mu.
+func (i *URInterpreter) request(ctx context.Context, line string) (string, error) {
+	if err := i.SendCommandContext(ctx, line); err != nil {
+		return "", err
+	}
+
+	for {
+		if n := bytes.IndexByte(i.pending, '\n'); n >= 0 {
+			reply := string(i.pending[:n])
+			i.pending = i.pending[n+1:]
+			return strings.TrimSpace(reply), nil
+		}
+
+		data, err := i.read(ctx)
+		if err != nil {
+			return "", err
+		}
+		i.pending = append(i.pending, data...)
+	}
+}
+
+// parseInterpreterAck parses "ack: <id>: <stmt>" or "discard: <reason>: <stmt>"
+func parseInterpreterAck(reply, stmt string) (int, error) {
+	parts := strings.SplitN(reply, ":", 3)
+	switch strings.TrimSpace(parts[0]) {
+	case "ack":
+		if len(parts) < 2 {
+			break
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			break
+		}
+		return id, nil
+	case "discard":
+		reason := ""
+		if len(parts) > 1 {
+			reason = strings.TrimSpace(parts[1])
+		}
+		return 0, fmt.Errorf(ErrStatementDiscarded, reason, stmt)
+	}
+	return 0, fmt.Errorf("invalid interpreter reply to %q: %q", stmt, reply)
+}
+
+// StartInterpreterMode sends a program that enters interpreter mode. The interpreter
+// client can connect once the program is running.
+func (c *URController) StartInterpreterMode() error {
+	return c.StartInterpreterModeContext(c.Ctx)
+}
+
+func (c *URController) StartInterpreterModeContext(ctx context.Context) error {
+	program := NewURScript("interpreter").Add("interpreter_mode()")
+	return c.SendCommandContext(ctx, program.String())
+}
@@ -0,0 +1,399 @@
+package ur
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IOGroup is a bank of digital pins
+type IOGroup string
+
+const (
+	IO_STANDARD     IOGroup = "standard"
+	IO_CONFIGURABLE IOGroup = "configurable"
+	IO_TOOL         IOGroup = "tool"
+)
+
+// Analog output domains
+const (
+	ANALOG_CURRENT = 0
+	ANALOG_VOLTAGE = 1
+)
+
+// RTDE recipes used by URIO
+const (
+	RECIPE_IO_OUTPUTS = "actual_digital_input_bits,actual_digital_output_bits," +
+		"standard_analog_input0,standard_analog_input1," +
+		"standard_analog_output0,standard_analog_output1," +
+		"tool_analog_input0,tool_analog_input1"
+	RECIPE_IO_INPUTS = "standard_digital_output_mask,standard_digital_output," +
+		"configurable_digital_output_mask,configurable_digital_output," +
+		"tool_digital_output_mask,tool_digital_output," +
+		"standard_analog_output_mask,standard_analog_output_type," +
+		"standard_analog_output_0,standard_analog_output_1"
+)
+
+// DigitalPin is a digital input or output pin
+type DigitalPin struct {
+	Group IOGroup
+	Index int
+}
+
+func (p DigitalPin) String() string {
+	return fmt.Sprintf("%s[%d]", p.Group, p.Index)
+}
+
+// bit returns the position of the pin in the RTDE digital bit masks
+func (p DigitalPin) bit() (uint, error) {
+	var size, offset int
+	switch p.Group {
+	case IO_STANDARD:
+		size, offset = 8, 0
+	case IO_CONFIGURABLE:
+		size, offset = 8, 8
+	case IO_TOOL:
+		size, offset = 2, 16
+	default:
+		return 0, fmt.Errorf("unknown I/O group: %q", p.Group)
+	}
+
+	if p.Index < 0 || p.Index >= size {
+		return 0, fmt.Errorf("pin %s out of range [0, %d]", p, size-1)
+	}
+	return uint(offset + p.Index), nil
+}
+
+// IOState is a snapshot of the robot's I/O. Analog values are in A or V depending on the domain.
+type IOState struct {
+	DigitalInputs    uint64
+	DigitalOutputs   uint64
+	AnalogInputs     [2]float64
+	AnalogOutputs    [2]float64
+	ToolAnalogInputs [2]float64
+	Timestamp        time.Time
+}
+
+// DigitalIn returns the state of a digital input
+func (s IOState) DigitalIn(pin DigitalPin) (bool, error) {
+	bit, err := pin.bit()
+	if err != nil {
+		return false, err
+	}
+	return s.DigitalInputs&(1<<bit) != 0, nil
+}
+
+// DigitalOut returns the state of a digital output
+func (s IOState) DigitalOut(pin DigitalPin) (bool, error) {
+	bit, err := pin.bit()
+	if err != nil {
+		return false, err
+	}
+	return s.DigitalOutputs&(1<<bit) != 0, nil
+}
+
+// IOEvent is a change of a digital pin
+type IOEvent struct {
+	Pin    DigitalPin
+	Output bool // true for outputs, false for inputs
+	Value  bool
+	Time   time.Time
+}
+
+type ioListener struct {
+	pin    DigitalPin
+	output bool
+	fn     func(IOEvent)
+}
+
+// URIO reads and sets the robot's I/O over RTDE
+type URIO struct {
+	receiver *URReceiver
+	inputs   *DataConfig
+	outputs  uint8 // output recipe ID, 0 before Setup
+
+	mu        sync.Mutex
+	state     *IOState
+	aliases   map[string]DigitalPin
+	listeners map[int]ioListener
+
+	edges          map[int]*edgeListener
+	nextListenerID int
+	running        bool
+}
+
+func NewIO(receiver *URReceiver) *URIO {
+	return &URIO{
+		receiver:  receiver,
+		aliases:   make(map[string]DigitalPin),
+		listeners: make(map[int]ioListener),
+		edges:     make(map[int]*edgeListener),
+	}
+}
+
+// Setup configures the RTDE recipes for reading and setting I/O.
+// Call it before StartDataExchange on the receiver.
+func (io *URIO) Setup() error {
+	return io.SetupContext(io.receiver.Ctx)
+}
+
+func (io *URIO) SetupContext(ctx context.Context) error {
+	resp, err := io.receiver.SendOutputSetupContext(ctx, RECIPE_IO_OUTPUTS)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(resp.Types, "NOT_FOUND") {
+		return fmt.Errorf("output setup rejected for %q: %s", RECIPE_IO_OUTPUTS, resp.Types)
+	}
+	io.outputs = resp.RecipeID
+
+	inputs, err := io.receiver.SendInputSetupContext(ctx, RECIPE_IO_INPUTS)
+	if err != nil {
+		return err
+	}
+	io.inputs = inputs
+	return nil
+}
+
+// SetAlias names a pin, e.g. "part_present"
+func (io *URIO) SetAlias(name string, pin DigitalPin) error {
+	if _, err := pin.bit(); err != nil {
+		return err
+	}
+
+	io.mu.Lock()
+	defer io.mu.Unlock()
+	io.aliases[name] = pin
+	return nil
+}
+
+// Pin returns the pin with the given alias
+func (io *URIO) Pin(name string) (DigitalPin, error) {
+	io.mu.Lock()
+	defer io.mu.Unlock()
+
+	pin, ok := io.aliases[name]
+	if !ok {
+		return DigitalPin{}, fmt.Errorf("unknown pin alias: %q", name)
+	}
+	return pin, nil
+}
+
+// OnChange calls fn whenever the digital input (or output) changes.
+// The returned function removes the callback.
+func (io *URIO) OnChange(pin DigitalPin, output bool, fn func(IOEvent)) (func(), error) {
+	if _, err := pin.bit(); err != nil {
+		return nil, err
+	}
+
+	io.mu.Lock()
+	defer io.mu.Unlock()
+
+	id := io.nextListenerID
+	io.nextListenerID++
+	io.listeners[id] = ioListener{pin: pin, output: output, fn: fn}
+
+	return func() {
+		io.mu.Lock()
+		defer io.mu.Unlock()
+		delete(io.listeners, id)
+	}, nil
+}
+
+// State returns the last state read by Update, or nil before the first update
+func (io *URIO) State() *IOState {
+	io.mu.Lock()
+	defer io.mu.Unlock()
+	return io.state
+}
+
+// Update reads the next data package of the I/O recipe, stores the state and fires change events
+func (io *URIO) Update() (IOState, error) {
+	return io.UpdateContext(io.receiver.Ctx)
+}
+
+func (io *URIO) UpdateContext(ctx context.Context) (IOState, error) {
+	if io.outputs == 0 {
+		return IOState{}, fmt.Errorf("I/O recipes are not set up")
+	}
+
+	data, err := io.receiver.ListenRecipeContext(ctx, io.outputs)
+	if err != nil {
+		return IOState{}, err
+	}
+
+	state, err := unpackIOState(data)
+	if err != nil {
+		return IOState{}, err
+	}
+
+	io.mu.Lock()
+	previous := io.state
+	io.state = &state
+	listeners := make([]ioListener, 0, len(io.listeners))
+	for _, l := range io.listeners {
+		listeners = append(listeners, l)
+	}
+
+	var edgeEvents []IOEvent
+	var edgeFns []func(IOEvent)
+	for _, l := range io.edges {
+		if event, ok := l.update(state); ok {
+			edgeEvents = append(edgeEvents, event)
+			edgeFns = append(edgeFns, l.fn)
+		}
+	}
+	io.mu.Unlock()
+
+	// Callbacks run outside the lock so they can use the I/O API
+	for i, fn := range edgeFns {
+		fn(edgeEvents[i])
+	}
+
+	if previous != nil {
+		for _, l := range listeners {
+			bit, _ := l.pin.bit()
+			before, after := previous.DigitalInputs, state.DigitalInputs
+			if l.output {
+				before, after = previous.DigitalOutputs, state.DigitalOutputs
+			}
+			if (before^after)&(1<<bit) != 0 {
+				l.fn(IOEvent{Pin: l.pin, Output: l.output, Value: after&(1<<bit) != 0, Time: state.Timestamp})
+			}
+		}
+	}
+
+	return state, nil
+}
+
+// Run calls Update until ctx is cancelled or reading fails
+func (io *URIO) Run(ctx context.Context) error {
+	io.mu.Lock()
+	io.running = true
+	io.mu.Unlock()
+
+	defer func() {
+		io.mu.Lock()
+		io.running = false
+		io.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if _, err := io.UpdateContext(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// SetDigitalOut sets a digital output
+func (io *URIO) SetDigitalOut(pin DigitalPin, value bool) error {
+	return io.SetDigitalOutContext(io.receiver.Ctx, pin, value)
+}
+
+func (io *URIO) SetDigitalOutContext(ctx context.Context, pin DigitalPin, value bool) error {
+	bit, err := pin.bit()
+	if err != nil {
+		return err
+	}
+	if io.inputs == nil {
+		return fmt.Errorf("I/O recipes are not set up")
+	}
+
+	var mask, out uint8 = 1 << (bit % 8), 0
+	if value {
+		out = mask
+	}
+
+	fields := io.emptyInputs()
+	switch pin.Group {
+	case IO_STANDARD:
+		fields["standard_digital_output_mask"], fields["standard_digital_output"] = mask, out
+	case IO_CONFIGURABLE:
+		fields["configurable_digital_output_mask"], fields["configurable_digital_output"] = mask, out
+	case IO_TOOL:
+		fields["tool_digital_output_mask"], fields["tool_digital_output"] = mask, out
+	}
+
+	return io.receiver.SendInputsContext(ctx, io.inputs, &DataObject{Fields: fields})
+}
+
+// SetAnalogOut sets a standard analog output. Value is a ratio in [0, 1] of the domain's range.
+func (io *URIO) SetAnalogOut(index int, value float64, domain int) error {
+	return io.SetAnalogOutContext(io.receiver.Ctx, index, value, domain)
+}
+
+func (io *URIO) SetAnalogOutContext(ctx context.Context, index int, value float64, domain int) error {
+	if index < 0 || index > 1 {
+		return fmt.Errorf("analog output %d out of range [0, 1]", index)
+	}
+	if value < 0 || value > 1 {
+		return fmt.Errorf("analog output value %f out of range [0, 1]", value)
+	}
+	if io.inputs == nil {
+		return fmt.Errorf("I/O recipes are not set up")
+	}
+
+	fields := io.emptyInputs()
+	fields["standard_analog_output_mask"] = uint8(1 << index)
+	fields["standard_analog_output_type"] = uint8(domain << index)
+	fields[fmt.Sprintf("standard_analog_output_%d", index)] = value
+
+	return io.receiver.SendInputsContext(ctx, io.inputs, &DataObject{Fields: fields})
+}
+
+// SetDigitalOutByName sets a digital output by alias
+func (io *URIO) SetDigitalOutByName(name string, value bool) error {
+	return io.SetDigitalOutByNameContext(io.receiver.Ctx, name, value)
+}
+
+func (io *URIO) SetDigitalOutByNameContext(ctx context.Context, name string, value bool) error {
+	pin, err := io.Pin(name)
+	if err != nil {
+		return err
+	}
+	return io.SetDigitalOutContext(ctx, pin, value)
+}
+
+// emptyInputs returns input fields with all masks cleared, so nothing changes unless set
+func (io *URIO) emptyInputs() map[string]interface{} {
+	fields := make(map[string]interface{})
+	for i, name := range io.inputs.Names {
+		switch io.inputs.Types[i] {
+		case "UINT8":
+			fields[name] = uint8(0)
+		case "DOUBLE":
+			fields[name] = 0.0
+		}
+	}
+	return fields
+}
+
+func unpackIOState(data []byte) (IOState, error) {
+	types := []string{"UINT64", "UINT64", "DOUBLE", "DOUBLE", "DOUBLE", "DOUBLE", "DOUBLE", "DOUBLE"}
+	if len(data) < 8*len(types) {
+		return IOState{}, fmt.Errorf("invalid data length for I/O recipe")
+	}
+
+	values := make([]interface{}, len(types))
+	offset := 0
+	for i, t := range types {
+		values[i], offset = UnpackField(data, offset, t)
+	}
+
+	return IOState{
+		DigitalInputs:    values[0].(uint64),
+		DigitalOutputs:   values[1].(uint64),
+		AnalogInputs:     [2]float64{values[2].(float64), values[3].(float64)},
+		AnalogOutputs:    [2]float64{values[4].(float64), values[5].(float64)},
+		ToolAnalogInputs: [2]float64{values[6].(float64), values[7].(float64)},
+		Timestamp:        time.Now(),
+	}, nil
+}
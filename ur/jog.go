@@ -0,0 +1,213 @@
+package ur
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// JogAxis is a joint or a Cartesian axis of the base frame to jog along
+type JogAxis int
+
+const (
+	JOG_JOINT_1 JogAxis = iota
+	JOG_JOINT_2
+	JOG_JOINT_3
+	JOG_JOINT_4
+	JOG_JOINT_5
+	JOG_JOINT_6
+	JOG_X
+	JOG_Y
+	JOG_Z
+	JOG_RX
+	JOG_RY
+	JOG_RZ
+)
+
+// Jog defaults
+const (
+	DEFAULT_JOG_ACCELERATION      = 1.0 // rad/s^2 for joints, m/s^2 for Cartesian axes
+	DEFAULT_JOG_HEARTBEAT_TIMEOUT = 200 * time.Millisecond
+)
+
+// Cartesian jog speed ceilings, the tool speed of the arms' specifications
+const (
+	MAX_JOG_TOOL_SPEED          = 1.0     // m/s along X, Y and Z
+	MAX_JOG_TOOL_ROTATION_SPEED = math.Pi // rad/s about RX, RY and RZ
+)
+
+// IsJoint reports whether the axis is a joint
+func (a JogAxis) IsJoint() bool {
+	return a >= JOG_JOINT_1 && a <= JOG_JOINT_6
+}
+
+func (a JogAxis) valid() bool {
+	return a >= JOG_JOINT_1 && a <= JOG_RZ
+}
+
+type JogOptions struct {
+	Acceleration     float64
+	HeartbeatTimeout time.Duration
+}
+
+type JogOption func(*JogOptions)
+
+func WithJogAcceleration(a float64) JogOption {
+	return func(opts *JogOptions) {
+		opts.Acceleration = a
+	}
+}
+
+func WithHeartbeatTimeout(d time.Duration) JogOption {
+	return func(opts *JogOptions) {
+		opts.HeartbeatTimeout = d
+	}
+}
+
+// Jogger moves an axis at constant speed for as long as Heartbeat keeps being called.
+// Every speed command runs until one heartbeat timeout after the last heartbeat, so the
+// arm starts decelerating at that point even if this process dies. The arm is at rest
+// within HeartbeatTimeout + speed/Acceleration of the last heartbeat.
+type Jogger struct {
+	controller *URController
+	axis       JogAxis
+	speed      float64
+	opts       JogOptions
+
+	mu        sync.Mutex
+	heartbeat time.Time
+
+	done chan struct{}
+	err  error
+}
+
+// Jog starts jogging axis at speed (rad/s or m/s, signed) until the heartbeat stops or ctx is cancelled
+func (c *URController) Jog(ctx context.Context, axis JogAxis, speed float64, options ...JogOption) (*Jogger, error) {
+	opts := JogOptions{
+		Acceleration:     DEFAULT_JOG_ACCELERATION,
+		HeartbeatTimeout: DEFAULT_JOG_HEARTBEAT_TIMEOUT,
+	}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	if !axis.valid() {
+		return nil, fmt.Errorf("invalid jog axis: %d", axis)
+	}
+	if opts.HeartbeatTimeout <= 0 {
+		return nil, fmt.Errorf("heartbeat timeout must be positive")
+	}
+
+	j := &Jogger{
+		controller: c,
+		axis:       axis,
+		speed:      speed,
+		opts:       opts,
+		heartbeat:  time.Now(),
+		done:       make(chan struct{}),
+	}
+
+	if axis.IsJoint() {
+		var qd [6]float64
+		qd[axis] = speed
+		if err := validateJointSpeeds(qd, opts.Acceleration, c.Model()); err != nil {
+			return nil, err
+		}
+	} else if err := validateJogToolSpeed(axis, speed); err != nil {
+		return nil, err
+	}
+
+	if err := j.move(opts.HeartbeatTimeout); err != nil {
+		return nil, err
+	}
+
+	go j.run(ctx)
+	return j, nil
+}
+
+// Heartbeat keeps the jog going for another heartbeat timeout
+func (j *Jogger) Heartbeat() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.heartbeat = time.Now()
+}
+
+// Done is closed once the stop command has been sent
+func (j *Jogger) Done() <-chan struct{} {
+	return j.done
+}
+
+// Err returns the error that ended the jog, if any
+func (j *Jogger) Err() error {
+	<-j.done
+	return j.err
+}
+
+func (j *Jogger) run(ctx context.Context) {
+	defer close(j.done)
+
+	// Refresh well within the timeout so the motion is continuous
+	ticker := time.NewTicker(j.opts.HeartbeatTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			j.err = j.stop()
+			return
+		case <-ticker.C:
+		}
+
+		j.mu.Lock()
+		remaining := j.opts.HeartbeatTimeout - time.Since(j.heartbeat)
+		j.mu.Unlock()
+
+		if remaining <= 0 {
+			slog.Info("Jog heartbeat lost, stopping", "axis", j.axis)
+			j.err = j.stop()
+			return
+		}
+
+		if err := j.move(remaining); err != nil {
+			slog.Error("Failed to refresh jog", "error", err)
+			j.err = err
+			return
+		}
+	}
+}
+
+// move sends a speed command that runs for d, the time left until the heartbeat expires
+func (j *Jogger) move(d time.Duration) error {
+	var v [6]float64
+	t := d.Seconds()
+
+	if j.axis.IsJoint() {
+		v[j.axis] = j.speed
+		return j.controller.SendCommand(SpeedJ(v, j.opts.Acceleration, t))
+	}
+
+	v[j.axis-JOG_X] = j.speed
+	return j.controller.SendCommand(SpeedL(v, j.opts.Acceleration, t))
+}
+
+func (j *Jogger) stop() error {
+	if j.axis.IsJoint() {
+		return j.controller.SendCommand(StopJ(j.opts.Acceleration))
+	}
+	return j.controller.SendCommand(StopL(j.opts.Acceleration))
+}
+
+// validateJogToolSpeed checks a Cartesian jog speed against the tool speed ceilings
+func validateJogToolSpeed(axis JogAxis, speed float64) error {
+	limit, unit := MAX_JOG_TOOL_SPEED, "m/s"
+	if axis >= JOG_RX {
+		limit, unit = MAX_JOG_TOOL_ROTATION_SPEED, "rad/s"
+	}
+	if math.IsNaN(speed) || math.Abs(speed) > limit {
+		return fmt.Errorf("jog speed %f %s exceeds the tool limit of %f %s", speed, unit, limit, unit)
+	}
+	return nil
+}
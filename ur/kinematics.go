@@ -0,0 +1,195 @@
+package ur
+
+import (
+	"fmt"
+	"math"
+)
+
+// Matrix4 is a homogeneous transformation matrix
+type Matrix4 [4][4]float64
+
+// Identity4 returns the identity transformation
+func Identity4() Matrix4 {
+	return Matrix4{
+		{1, 0, 0, 0},
+		{0, 1, 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// Mul returns m * n
+func (m Matrix4) Mul(n Matrix4) Matrix4 {
+	var out Matrix4
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 4; k++ {
+				out[i][j] += m[i][k] * n[k][j]
+			}
+		}
+	}
+	return out
+}
+
+// Translation returns the translational part of the transformation
+func (m Matrix4) Translation() [3]float64 {
+	return [3]float64{m[0][3], m[1][3], m[2][3]}
+}
+
+// dhTransform returns the standard Denavit-Hartenberg link transformation
+func dhTransform(theta, d, a, alpha float64) Matrix4 {
+	ct, st := math.Cos(theta), math.Sin(theta)
+	ca, sa := math.Cos(alpha), math.Sin(alpha)
+	return Matrix4{
+		{ct, -st * ca, st * sa, a * ct},
+		{st, ct * ca, -ct * sa, a * st},
+		{0, sa, ca, d},
+		{0, 0, 0, 1},
+	}
+}
+
+// ForwardKinematics returns the flange transformation in the base frame for the given joint positions
+func (m *URModel) ForwardKinematics(q URPosition) Matrix4 {
+	t := Identity4()
+	for i := 0; i < 6; i++ {
+		t = t.Mul(dhTransform(q[i], m.DH.D[i], m.DH.A[i], m.DH.Alpha[i]))
+	}
+	return t
+}
+
+// ForwardKinematicsPose returns the flange pose in the base frame for the given joint positions
+func (m *URModel) ForwardKinematicsPose(q URPosition) URPose {
+	return PoseFromMatrix(m.ForwardKinematics(q))
+}
+
+const (
+	ikMaxIterations = 200
+	ikTolerance     = 1e-6
+	ikDamping       = 1e-3
+	ikStep          = 1e-6
+	ikMaxStep       = 0.2
+)
+
+// DefaultIKSeed is the seed used when none is given: shoulder up, elbow bent and the tool
+// pointing down. All zero joints cannot be used, the stretched elbow and aligned wrist are singular.
+var DefaultIKSeed = URPosition{0, -math.Pi / 2, math.Pi / 2, -math.Pi / 2, -math.Pi / 2, 0}
+
+// InverseKinematics numerically solves for joint positions that place the flange at target.
+// The solver starts at seed and converges to the solution closest to it, so pass the current
+// or a neighbouring waypoint's joints to stay in the same configuration. A zero seed is
+// replaced by DefaultIKSeed.
+func (m *URModel) InverseKinematics(target URPose, seed URPosition) (URPosition, error) {
+	if seed == (URPosition{}) {
+		seed = DefaultIKSeed
+	}
+
+	goal := target.Matrix()
+	q := seed
+
+	for i := 0; i < ikMaxIterations; i++ {
+		current := m.ForwardKinematics(q)
+		e := poseError(goal, current)
+		if norm6(e) < ikTolerance {
+			return q, m.checkJointRanges(q)
+		}
+
+		var jac [6][6]float64
+		for j := 0; j < 6; j++ {
+			dq := q
+			dq[j] += ikStep
+			col := poseError(m.ForwardKinematics(dq), current)
+			for r := 0; r < 6; r++ {
+				jac[r][j] = col[r] / ikStep
+			}
+		}
+
+		// Damped least squares: dq = J^T (J J^T + lambda^2 I)^-1 e
+		var jjt [6][6]float64
+		for r := 0; r < 6; r++ {
+			for c := 0; c < 6; c++ {
+				for k := 0; k < 6; k++ {
+					jjt[r][c] += jac[r][k] * jac[c][k]
+				}
+			}
+			jjt[r][r] += ikDamping * ikDamping
+		}
+
+		y, ok := solve6(jjt, e)
+		if !ok {
+			return q, fmt.Errorf("inverse kinematics: singular configuration")
+		}
+		var step [6]float64
+		for j := 0; j < 6; j++ {
+			for r := 0; r < 6; r++ {
+				step[j] += jac[r][j] * y[r]
+			}
+		}
+
+		// Limit the step to keep the solver in the seed's configuration
+		scale := math.Min(1, ikMaxStep/norm6(step))
+		for j := 0; j < 6; j++ {
+			q[j] = seed[j] + math.Remainder(q[j]+step[j]*scale-seed[j], 2*math.Pi)
+		}
+	}
+
+	return q, fmt.Errorf("inverse kinematics: no solution for %s", target)
+}
+
+func (m *URModel) checkJointRanges(q URPosition) error {
+	for j, v := range q {
+		if !m.JointRanges[j].Contains(v) {
+			return fmt.Errorf("inverse kinematics: joint %d at %.4f rad outside range", j+1, v)
+		}
+	}
+	return nil
+}
+
+// poseError returns the translation and rotation vector taking b to a
+func poseError(a, b Matrix4) [6]float64 {
+	pa, pb := a.Translation(), b.Translation()
+	rv := MatrixToRotVec(a.Rotation().Mul(b.Rotation().Transpose()))
+	return [6]float64{pa[0] - pb[0], pa[1] - pb[1], pa[2] - pb[2], rv[0], rv[1], rv[2]}
+}
+
+func norm6(v [6]float64) float64 {
+	sum := 0.0
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+// solve6 solves a x = b with Gaussian elimination and partial pivoting
+func solve6(a [6][6]float64, b [6]float64) ([6]float64, bool) {
+	for col := 0; col < 6; col++ {
+		pivot := col
+		for r := col + 1; r < 6; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-15 {
+			return b, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		for r := col + 1; r < 6; r++ {
+			f := a[r][col] / a[col][col]
+			for c := col; c < 6; c++ {
+				a[r][c] -= f * a[col][c]
+			}
+			b[r] -= f * b[col]
+		}
+	}
+
+	var x [6]float64
+	for r := 5; r >= 0; r-- {
+		sum := b[r]
+		for c := r + 1; c < 6; c++ {
+			sum -= a[r][c] * x[c]
+		}
+		x[r] = sum / a[r][r]
+	}
+	return x, true
+}
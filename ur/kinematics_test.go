@@ -0,0 +1,104 @@
+package ur
+
+import (
+	"math"
+	"testing"
+)
+
+const kinematicsTolerance = 1e-5
+
+func samePosition(a, b URPosition, tolerance float64) bool {
+	for j := range a {
+		if math.Abs(a[j]-b[j]) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func TestInverseKinematicsRoundTrip(t *testing.T) {
+	configurations := []struct {
+		name string
+		q    URPosition
+	}{
+		{"default seed", DefaultIKSeed},
+		{"cell home", DefaultPositionMap.Home},
+		{"over device", DefaultPositionMap.OverDevice},
+		{"pick letter", DefaultPositionMap.PickLetter},
+		{"elbow down", URPosition{0.5, -2.2, -1.4, -1.1, 1.2, 0.3}},
+		{"reaching out", URPosition{-1.0, -0.6, 0.9, -2.0, -1.0, 2.5}},
+	}
+
+	for _, name := range []string{"UR3", "UR5", "UR10", "UR3e", "UR5e", "UR10e", "UR16e", "UR20", "UR30"} {
+		model, err := LookupModel(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, c := range configurations {
+			t.Run(name+"/"+c.name, func(t *testing.T) {
+				target := model.ForwardKinematicsPose(c.q)
+
+				// A seed near the configuration must lead back to it
+				seed := c.q
+				for j := range seed {
+					seed[j] += 0.05
+				}
+				q, err := model.InverseKinematics(target, seed)
+				if err != nil {
+					t.Fatalf("InverseKinematics(%v): %v", target, err)
+				}
+				if !samePosition(q, c.q, kinematicsTolerance) {
+					t.Errorf("InverseKinematics(%v) = %v, want %v", target, q, c.q)
+				}
+				if got := model.ForwardKinematicsPose(q); !samePose(got, target, kinematicsTolerance) {
+					t.Errorf("ForwardKinematicsPose(%v) = %v, want %v", q, got, target)
+				}
+			})
+		}
+	}
+}
+
+func TestInverseKinematicsWithoutSeed(t *testing.T) {
+	model, err := LookupModel("UR5e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Poses near the default seed, including one straight below the flange of it
+	for _, q := range []URPosition{
+		DefaultIKSeed,
+		{0.3, -1.3, 1.4, -1.7, -1.6, 0.2},
+		{-0.4, -1.9, 1.8, -1.4, -1.5, -0.3},
+	} {
+		target := model.ForwardKinematicsPose(q)
+		got, err := model.InverseKinematics(target, URPosition{})
+		if err != nil {
+			t.Fatalf("InverseKinematics(%v) without seed: %v", target, err)
+		}
+		if pose := model.ForwardKinematicsPose(got); !samePose(pose, target, kinematicsTolerance) {
+			t.Errorf("InverseKinematics(%v) without seed reaches %v", target, pose)
+		}
+	}
+}
+
+func TestForwardKinematicsZero(t *testing.T) {
+	model, err := LookupModel("UR5e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With all joints at zero the arm is stretched out along the base -x axis
+	// (a2 and a3 are negative), with the wrist offsets along y and z
+	pose := model.ForwardKinematicsPose(URPosition{})
+	want := [3]float64{
+		model.DH.A[1] + model.DH.A[2],
+		-(model.DH.D[3] + model.DH.D[5]),
+		model.DH.D[0] - model.DH.D[4],
+	}
+	for i, v := range pose.Position() {
+		if math.Abs(v-want[i]) > 1e-9 {
+			t.Fatalf("ForwardKinematicsPose(0) = %v, want position %v", pose, want)
+		}
+	}
+}
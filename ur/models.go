@@ -0,0 +1,292 @@
+package ur
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	SERIES_CB3 = "CB3"
+	SERIES_E   = "e-Series"
+)
+
+// RTDE frequency ceilings per controller generation
+const (
+	MAX_FREQ_CB3      = 125.0
+	MAX_FREQ_E_SERIES = 500.0
+)
+
+// JointRange is an inclusive joint position range in radians
+type JointRange struct {
+	Min float64
+	Max float64
+}
+
+// Contains reports whether q lies within the range
+func (r JointRange) Contains(q float64) bool {
+	return q >= r.Min && q <= r.Max
+}
+
+// DHParams holds the standard Denavit-Hartenberg parameters of an arm (meters and radians)
+type DHParams struct {
+	A     [6]float64
+	D     [6]float64
+	Alpha [6]float64
+}
+
+// URModel describes the physical limits and capabilities of a robot arm
+type URModel struct {
+	Name    string
+	Series  string
+	Reach   float64 // meters
+	Payload float64 // kg
+
+	JointRanges           [6]JointRange
+	MaxJointSpeeds        [6]float64 // rad/s
+	MaxJointAccelerations [6]float64 // rad/s^2
+
+	DH DHParams
+
+	MaxRTDEFrequency float64 // Hz
+}
+
+// MaxVelocity returns the lowest joint speed limit of the model
+func (m *URModel) MaxVelocity() float64 {
+	return minOf(m.MaxJointSpeeds[:])
+}
+
+// MaxAcceleration returns the lowest joint acceleration limit of the model
+func (m *URModel) MaxAcceleration() float64 {
+	return minOf(m.MaxJointAccelerations[:])
+}
+
+// IsESeries reports whether the model runs on an e-Series controller
+func (m *URModel) IsESeries() bool {
+	return m.Series == SERIES_E
+}
+
+func (m *URModel) String() string {
+	return fmt.Sprintf("%s (%s)", m.Name, m.Series)
+}
+
+var urAlpha = [6]float64{math.Pi / 2, 0, 0, math.Pi / 2, -math.Pi / 2, 0}
+
+func symmetricRanges(limits ...float64) [6]JointRange {
+	var ranges [6]JointRange
+	for i, l := range limits {
+		ranges[i] = JointRange{Min: -l, Max: l}
+	}
+	return ranges
+}
+
+func degsPerSec(values ...float64) [6]float64 {
+	var out [6]float64
+	for i, v := range values {
+		out[i] = DegToRad(v)
+	}
+	return out
+}
+
+var (
+	fullTurn = 2 * math.Pi
+	halfTurn = math.Pi
+	endless  = math.Inf(1)
+
+	// Acceleration ceilings follow the PolyScope joint acceleration limit
+	defaultJointAccelerations = degsPerSec(800, 800, 800, 800, 800, 800)
+)
+
+// models is the catalog of known robot models, keyed by name. It is only read through
+// LookupModel, which hands out copies. UR16, UR20 and UR30 only exist as e-Series arms.
+var models = map[string]URModel{
+	"UR3": {
+		Name:                  "UR3",
+		Series:                SERIES_CB3,
+		Reach:                 0.5,
+		Payload:               3,
+		JointRanges:           symmetricRanges(fullTurn, fullTurn, fullTurn, fullTurn, fullTurn, endless),
+		MaxJointSpeeds:        degsPerSec(180, 180, 180, 360, 360, 360),
+		MaxJointAccelerations: defaultJointAccelerations,
+		DH: DHParams{
+			A:     [6]float64{0, -0.24365, -0.21325, 0, 0, 0},
+			D:     [6]float64{0.1519, 0, 0, 0.11235, 0.08535, 0.0819},
+			Alpha: urAlpha,
+		},
+		MaxRTDEFrequency: MAX_FREQ_CB3,
+	},
+	"UR5": {
+		Name:                  "UR5",
+		Series:                SERIES_CB3,
+		Reach:                 0.85,
+		Payload:               5,
+		JointRanges:           symmetricRanges(fullTurn, fullTurn, fullTurn, fullTurn, fullTurn, fullTurn),
+		MaxJointSpeeds:        degsPerSec(180, 180, 180, 180, 180, 180),
+		MaxJointAccelerations: defaultJointAccelerations,
+		DH: DHParams{
+			A:     [6]float64{0, -0.425, -0.39225, 0, 0, 0},
+			D:     [6]float64{0.089159, 0, 0, 0.10915, 0.09465, 0.0823},
+			Alpha: urAlpha,
+		},
+		MaxRTDEFrequency: MAX_FREQ_CB3,
+	},
+	"UR10": {
+		Name:                  "UR10",
+		Series:                SERIES_CB3,
+		Reach:                 1.3,
+		Payload:               10,
+		JointRanges:           symmetricRanges(fullTurn, fullTurn, fullTurn, fullTurn, fullTurn, fullTurn),
+		MaxJointSpeeds:        degsPerSec(120, 120, 180, 180, 180, 180),
+		MaxJointAccelerations: defaultJointAccelerations,
+		DH: DHParams{
+			A:     [6]float64{0, -0.612, -0.5723, 0, 0, 0},
+			D:     [6]float64{0.1273, 0, 0, 0.163941, 0.1157, 0.0922},
+			Alpha: urAlpha,
+		},
+		MaxRTDEFrequency: MAX_FREQ_CB3,
+	},
+	"UR3e": {
+		Name:                  "UR3e",
+		Series:                SERIES_E,
+		Reach:                 0.5,
+		Payload:               3,
+		JointRanges:           symmetricRanges(fullTurn, fullTurn, fullTurn, fullTurn, fullTurn, endless),
+		MaxJointSpeeds:        degsPerSec(180, 180, 180, 360, 360, 360),
+		MaxJointAccelerations: defaultJointAccelerations,
+		DH: DHParams{
+			A:     [6]float64{0, -0.24355, -0.2132, 0, 0, 0},
+			D:     [6]float64{0.15185, 0, 0, 0.13105, 0.08535, 0.0921},
+			Alpha: urAlpha,
+		},
+		MaxRTDEFrequency: MAX_FREQ_E_SERIES,
+	},
+	"UR5e": {
+		Name:                  "UR5e",
+		Series:                SERIES_E,
+		Reach:                 0.85,
+		Payload:               5,
+		JointRanges:           symmetricRanges(fullTurn, fullTurn, fullTurn, fullTurn, fullTurn, fullTurn),
+		MaxJointSpeeds:        degsPerSec(180, 180, 180, 180, 180, 180),
+		MaxJointAccelerations: defaultJointAccelerations,
+		DH: DHParams{
+			A:     [6]float64{0, -0.425, -0.3922, 0, 0, 0},
+			D:     [6]float64{0.1625, 0, 0, 0.1333, 0.0997, 0.0996},
+			Alpha: urAlpha,
+		},
+		MaxRTDEFrequency: MAX_FREQ_E_SERIES,
+	},
+	"UR10e": {
+		Name:                  "UR10e",
+		Series:                SERIES_E,
+		Reach:                 1.3,
+		Payload:               12.5,
+		JointRanges:           symmetricRanges(fullTurn, fullTurn, halfTurn, fullTurn, fullTurn, fullTurn),
+		MaxJointSpeeds:        degsPerSec(120, 120, 180, 180, 180, 180),
+		MaxJointAccelerations: defaultJointAccelerations,
+		DH: DHParams{
+			A:     [6]float64{0, -0.6127, -0.57155, 0, 0, 0},
+			D:     [6]float64{0.1807, 0, 0, 0.17415, 0.11985, 0.11655},
+			Alpha: urAlpha,
+		},
+		MaxRTDEFrequency: MAX_FREQ_E_SERIES,
+	},
+	"UR16e": {
+		Name:                  "UR16e",
+		Series:                SERIES_E,
+		Reach:                 0.9,
+		Payload:               16,
+		JointRanges:           symmetricRanges(fullTurn, fullTurn, halfTurn, fullTurn, fullTurn, fullTurn),
+		MaxJointSpeeds:        degsPerSec(120, 120, 180, 180, 180, 180),
+		MaxJointAccelerations: defaultJointAccelerations,
+		DH: DHParams{
+			A:     [6]float64{0, -0.4784, -0.36, 0, 0, 0},
+			D:     [6]float64{0.1807, 0, 0, 0.17415, 0.11985, 0.11655},
+			Alpha: urAlpha,
+		},
+		MaxRTDEFrequency: MAX_FREQ_E_SERIES,
+	},
+	"UR20": {
+		Name:                  "UR20",
+		Series:                SERIES_E,
+		Reach:                 1.75,
+		Payload:               20,
+		JointRanges:           symmetricRanges(fullTurn, fullTurn, halfTurn, fullTurn, fullTurn, fullTurn),
+		MaxJointSpeeds:        degsPerSec(120, 120, 150, 210, 210, 210),
+		MaxJointAccelerations: defaultJointAccelerations,
+		DH: DHParams{
+			A:     [6]float64{0, -0.862, -0.7287, 0, 0, 0},
+			D:     [6]float64{0.2363, 0, 0, 0.201, 0.1593, 0.1543},
+			Alpha: urAlpha,
+		},
+		MaxRTDEFrequency: MAX_FREQ_E_SERIES,
+	},
+	"UR30": {
+		Name:                  "UR30",
+		Series:                SERIES_E,
+		Reach:                 1.3,
+		Payload:               30,
+		JointRanges:           symmetricRanges(fullTurn, fullTurn, halfTurn, fullTurn, fullTurn, fullTurn),
+		MaxJointSpeeds:        degsPerSec(120, 120, 150, 210, 210, 210),
+		MaxJointAccelerations: defaultJointAccelerations,
+		DH: DHParams{
+			A:     [6]float64{0, -0.637, -0.5037, 0, 0, 0},
+			D:     [6]float64{0.2363, 0, 0, 0.201, 0.1593, 0.1543},
+			Alpha: urAlpha,
+		},
+		MaxRTDEFrequency: MAX_FREQ_E_SERIES,
+	},
+}
+
+// ModelNames returns the names of all models in the catalog, sorted
+func ModelNames() []string {
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupModel finds a model by name, ignoring case. It returns a copy that the caller
+// can change without affecting the catalog.
+func LookupModel(name string) (*URModel, error) {
+	for key, model := range models {
+		if strings.EqualFold(key, name) {
+			return &model, nil
+		}
+	}
+	return nil, fmt.Errorf(ErrUnknownModel, name)
+}
+
+// DetectModel resolves a model from the robot type reported by the controller
+// (e.g. "UR5" from the dashboard's "get robot model") and the controller version.
+// Controllers with major version 5 or later are e-Series.
+func DetectModel(robotType string, version ControlVersion) (*URModel, error) {
+	name := strings.TrimSpace(robotType)
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "e"), "E")
+
+	if version.Major >= 5 {
+		if model, err := LookupModel(name + "e"); err == nil {
+			return model, nil
+		}
+	}
+
+	model, err := LookupModel(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if model.IsESeries() != (version.Major >= 5) {
+		return nil, fmt.Errorf(ErrModelSeriesMismatch, model.Name, version.Major)
+	}
+	return model, nil
+}
+
+func minOf(values []float64) float64 {
+	m := math.Inf(1)
+	for _, v := range values {
+		m = math.Min(m, v)
+	}
+	return m
+}
@@ -1,8 +1,10 @@
 package ur
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"math"
 	"strings"
 )
 
@@ -11,6 +13,12 @@ const (
 	DEFAULT_VELOCITY     = 12.0
 )
 
+// movel/movep defaults, in m/s^2 and m/s
+const (
+	DEFAULT_TOOL_ACCELERATION = 1.2
+	DEFAULT_TOOL_VELOCITY     = 0.25
+)
+
 const (
 	MOVE_J = "movej"
 	MOVE_L = "movel"
@@ -24,19 +32,44 @@ type MoveCmd struct {
 	Iterations int
 	Type       string
 
+	// Relative moves, used instead of the joint targets above
+	Offset      *URPose       // Optional
+	OffsetFrame RelativeFrame // Frame the offset is expressed in
+	From        *URPose       // Optional, TCP pose to move from. Defaults to the actual TCP pose.
+
 	Acceleration float64
 	Velocity     float64
+	Blend        float64 // Optional, blend radius in meters
+}
+
+// moveArgs returns the acceleration, velocity and blend arguments of the move
+func (cmd *MoveCmd) moveArgs() string {
+	args := fmt.Sprintf("a=%f, v=%f", cmd.Acceleration, cmd.Velocity)
+	if cmd.Blend > 0 {
+		args += fmt.Sprintf(", r=%f", cmd.Blend)
+	}
+	return args
 }
 
 func (cmd *MoveCmd) String() string {
+	if cmd.Offset != nil {
+		return cmd.relativeString()
+	}
+
 	if cmd.Type == "" {
 		cmd.Type = MOVE_J
 	}
 	if cmd.Acceleration <= 0 {
 		cmd.Acceleration = DEFAULT_ACCELERATION
+		if cmd.isLinear() {
+			cmd.Acceleration = DEFAULT_TOOL_ACCELERATION
+		}
 	}
 	if cmd.Velocity <= 0 {
 		cmd.Velocity = DEFAULT_VELOCITY
+		if cmd.isLinear() {
+			cmd.Velocity = DEFAULT_TOOL_VELOCITY
+		}
 	}
 
 	if cmd.Iterations > 0 && cmd.PosB != nil {
@@ -44,15 +77,15 @@ func (cmd *MoveCmd) String() string {
 		loopSeq.WriteString("i = 0\n")
 		loopSeq.WriteString(fmt.Sprintf("  while i < %d:\n", cmd.Iterations))
 
-		loopSeq.WriteString(fmt.Sprintf("  %s(%s, a=%f, v=%f)\n", cmd.Type, floatArrayToString(cmd.PosA[:]), cmd.Acceleration, cmd.Velocity))
+		loopSeq.WriteString(fmt.Sprintf("  %s(%s, %s)\n", cmd.Type, floatArrayToString(cmd.PosA[:]), cmd.moveArgs()))
 
 		if cmd.ViaPos != nil {
 			for _, viaPos := range cmd.ViaPos {
-				loopSeq.WriteString(fmt.Sprintf("  %s(%s, a=%f, v=%f)\n", cmd.Type, floatArrayToString(viaPos[:]), cmd.Acceleration, cmd.Velocity))
+				loopSeq.WriteString(fmt.Sprintf("  %s(%s, %s)\n", cmd.Type, floatArrayToString(viaPos[:]), cmd.moveArgs()))
 			}
 		}
 
-		loopSeq.WriteString(fmt.Sprintf("  %s(%s, a=%f, v=%f)\n", cmd.Type, floatArrayToString(cmd.PosB[:]), cmd.Acceleration, cmd.Velocity))
+		loopSeq.WriteString(fmt.Sprintf("  %s(%s, %s)\n", cmd.Type, floatArrayToString(cmd.PosB[:]), cmd.moveArgs()))
 		loopSeq.WriteString("  i = i + 1\n")
 
 		loopSeq.WriteString("end\n")
@@ -60,14 +93,14 @@ func (cmd *MoveCmd) String() string {
 	}
 
 	// Default single move
-	var s = fmt.Sprintf("%s(%s, a=%f, v=%f)", cmd.Type, floatArrayToString(cmd.PosA[:]), cmd.Acceleration, cmd.Velocity)
+	var s = fmt.Sprintf("%s(%s, %s)", cmd.Type, floatArrayToString(cmd.PosA[:]), cmd.moveArgs())
 	if cmd.ViaPos != nil {
 		for _, viaPos := range cmd.ViaPos {
-			s += fmt.Sprintf("\n%s(%s, a=%f, v=%f)", cmd.Type, floatArrayToString(viaPos[:]), cmd.Acceleration, cmd.Velocity)
+			s += fmt.Sprintf("\n%s(%s, %s)", cmd.Type, floatArrayToString(viaPos[:]), cmd.moveArgs())
 		}
 	}
 	if cmd.PosB != nil {
-		s += fmt.Sprintf("\n%s(%s, a=%f, v=%f)", cmd.Type, floatArrayToString(cmd.PosB[:]), cmd.Acceleration, cmd.Velocity)
+		s += fmt.Sprintf("\n%s(%s, %s)", cmd.Type, floatArrayToString(cmd.PosB[:]), cmd.moveArgs())
 	}
 	return s
 }
@@ -75,15 +108,22 @@ func (cmd *MoveCmd) String() string {
 type MoveOptions struct {
 	Acceleration float64
 	Velocity     float64
+	From         *URPose
 }
 
-// Default options
-func defaultMoveOptions() MoveOptions {
-	slog.Info("Using default move options", "acceleration", DEFAULT_ACCELERATION, "velocity", DEFAULT_VELOCITY)
-	return MoveOptions{
+// Default options, capped by the model's joint limits when the model is known
+func defaultMoveOptions(model *URModel) MoveOptions {
+	opts := MoveOptions{
 		Acceleration: DEFAULT_ACCELERATION,
 		Velocity:     DEFAULT_VELOCITY,
 	}
+	if model != nil {
+		opts.Acceleration = math.Min(opts.Acceleration, model.MaxAcceleration())
+		opts.Velocity = math.Min(opts.Velocity, model.MaxVelocity())
+	}
+
+	slog.Info("Using default move options", "acceleration", opts.Acceleration, "velocity", opts.Velocity)
+	return opts
 }
 
 type MoveOption func(*MoveOptions)
@@ -100,12 +140,23 @@ func WithVelocity(velocity float64) MoveOption {
 	}
 }
 
+// WithFromPose sets the TCP pose a relative move starts from, instead of the actual TCP pose
+func WithFromPose(pose URPose) MoveOption {
+	return func(opts *MoveOptions) {
+		opts.From = &pose
+	}
+}
+
 func (c *URController) MoveJ(joints URPosition, options ...MoveOption) error {
+	return c.MoveJContext(c.Ctx, joints, options...)
+}
+
+func (c *URController) MoveJContext(ctx context.Context, joints URPosition, options ...MoveOption) error {
 	if len(joints) != 6 {
 		return fmt.Errorf(ErrInvalidNumberOfJoints, len(joints))
 	}
 
-	opts := defaultMoveOptions()
+	opts := defaultMoveOptions(c.Model())
 
 	for _, opt := range options {
 		opt(&opts)
@@ -117,53 +168,116 @@ func (c *URController) MoveJ(joints URPosition, options ...MoveOption) error {
 		Velocity:     opts.Velocity,
 	}
 
-	return c.SendCommand(cmd.String())
+	return c.sendMoves(ctx, cmd.String(), []MoveCmd{cmd})
+}
+
+// isLinear reports whether the move is in tool space, with speeds in m/s and m/s^2
+func (cmd MoveCmd) isLinear() bool {
+	return cmd.Type == MOVE_L || cmd.Type == MOVE_P
+}
+
+// withDefaults fills in the type, acceleration and velocity left unset on the command.
+// opts are joint space defaults; movel and movep get the tool defaults instead.
+func (cmd MoveCmd) withDefaults(opts MoveOptions) MoveCmd {
+	if cmd.Offset != nil {
+		return cmd.withRelativeDefaults()
+	}
+
+	if cmd.Type == "" {
+		cmd.Type = MOVE_J
+	}
+	if cmd.isLinear() {
+		opts.Acceleration = DEFAULT_TOOL_ACCELERATION
+		opts.Velocity = DEFAULT_TOOL_VELOCITY
+	}
+	if cmd.Acceleration == 0 {
+		cmd.Acceleration = opts.Acceleration
+	}
+	if cmd.Velocity == 0 {
+		cmd.Velocity = opts.Velocity
+	}
+	return cmd
 }
 
 func (c *URController) MoveJSequence(cmds []MoveCmd) error {
-	programSeq := "def move_sequence():\n"
+	return c.moveSequence(c.Ctx, cmds)
+}
 
-	for _, cmd := range cmds {
-		s := cmd.String()
-		lines := strings.Split(s, "\n")
-		for i, line := range lines {
-			lines[i] = "  " + line
-		}
-		programSeq += strings.Join(lines, "\n") + "\n"
+func (c *URController) MoveJSequenceContext(ctx context.Context, cmds []MoveCmd) error {
+	return c.moveSequence(ctx, cmds)
+}
+
+func (c *URController) moveSequence(ctx context.Context, cmds []MoveCmd) error {
+	opts := defaultMoveOptions(c.Model())
+
+	resolved := make([]MoveCmd, len(cmds))
+	for i, cmd := range cmds {
+		resolved[i] = cmd.withDefaults(opts)
 	}
 
-	programSeq += "end\n"
+	program := NewURScript("move_sequence")
+	for _, cmd := range resolved {
+		program.Move(cmd)
+	}
 
-	return c.SendCommand(programSeq)
+	return c.sendMoves(ctx, program.String(), resolved)
 }
 
 func (c *URController) DoWork() error {
-	positions := []MoveCmd{
+	return c.DoWorkContext(c.Ctx)
+}
+
+func (c *URController) DoWorkContext(ctx context.Context) error {
+	cmds, err := c.workSequence()
+	if err != nil {
+		return err
+	}
+	return c.moveSequence(ctx, cmds)
+}
+
+// workSequence builds the pick cycle from the named positions in the controller's store.
+// Positions changed in the deprecated Positions field override the store.
+func (c *URController) workSequence() ([]MoveCmd, error) {
+	positions := make(map[string]URPosition)
+	preset, changed := c.preset.ToMap(), c.Positions.ToMap()
+	for _, name := range []string{"home", "over_device", "pick_device", "over_letter", "pick_letter"} {
+		if changed[name] != preset[name] {
+			positions[name] = changed[name]
+			continue
+		}
+		joints, err := c.JointsFor(name)
+		if err != nil {
+			return nil, err
+		}
+		positions[name] = joints
+	}
+
+	pickDevice := positions["pick_device"]
+	pickLetter := positions["pick_letter"]
+	return []MoveCmd{
 		{
-			PosA: c.Positions.Home,
+			PosA: positions["home"],
 			Type: MOVE_J,
 		},
 		{
-			PosA:       c.Positions.OverDevice,
-			PosB:       &c.Positions.PickDevice,
+			PosA:       positions["over_device"],
+			PosB:       &pickDevice,
 			Iterations: 3,
 			Type:       MOVE_J,
 		},
 		{
-			PosA: c.Positions.Home,
+			PosA: positions["home"],
 			Type: MOVE_J,
 		},
 		{
-			PosA:       c.Positions.OverLetter,
-			PosB:       &c.Positions.PickLetter,
+			PosA:       positions["over_letter"],
+			PosB:       &pickLetter,
 			Iterations: 3,
 			Type:       MOVE_J,
 		},
 		{
-			PosA: c.Positions.Home,
+			PosA: positions["home"],
 			Type: MOVE_J,
 		},
-	}
-
-	return c.MoveJSequence(positions)
+	}, nil
 }
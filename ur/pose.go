@@ -0,0 +1,311 @@
+package ur
+
+import (
+	"fmt"
+	"math"
+)
+
+// URPose is a Cartesian pose in URScript form: x, y, z in meters followed by
+// a rotation vector (axis-angle) rx, ry, rz in radians
+type URPose [6]float64
+
+// Matrix3 is a 3x3 rotation matrix
+type Matrix3 [3][3]float64
+
+// Quaternion is a unit quaternion with scalar part W
+type Quaternion struct {
+	W, X, Y, Z float64
+}
+
+// Position returns the translational part of the pose
+func (p URPose) Position() [3]float64 {
+	return [3]float64{p[0], p[1], p[2]}
+}
+
+// RotVec returns the rotation vector of the pose
+func (p URPose) RotVec() [3]float64 {
+	return [3]float64{p[3], p[4], p[5]}
+}
+
+// String formats the pose as a URScript pose literal
+func (p URPose) String() string {
+	return "p" + floatArrayToString(p[:])
+}
+
+// NewPose builds a pose from a position and a rotation matrix
+func NewPose(pos [3]float64, rot Matrix3) URPose {
+	rv := MatrixToRotVec(rot)
+	return URPose{pos[0], pos[1], pos[2], rv[0], rv[1], rv[2]}
+}
+
+// Matrix returns the homogeneous transformation of the pose
+func (p URPose) Matrix() Matrix4 {
+	r := RotVecToMatrix(p.RotVec())
+	return Matrix4{
+		{r[0][0], r[0][1], r[0][2], p[0]},
+		{r[1][0], r[1][1], r[1][2], p[1]},
+		{r[2][0], r[2][1], r[2][2], p[2]},
+		{0, 0, 0, 1},
+	}
+}
+
+// PoseFromMatrix converts a homogeneous transformation to a pose
+func PoseFromMatrix(m Matrix4) URPose {
+	return NewPose(m.Translation(), m.Rotation())
+}
+
+// Rotation returns the rotational part of the transformation
+func (m Matrix4) Rotation() Matrix3 {
+	return Matrix3{
+		{m[0][0], m[0][1], m[0][2]},
+		{m[1][0], m[1][1], m[1][2]},
+		{m[2][0], m[2][1], m[2][2]},
+	}
+}
+
+// Mul returns m * n
+func (m Matrix3) Mul(n Matrix3) Matrix3 {
+	var out Matrix3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				out[i][j] += m[i][k] * n[k][j]
+			}
+		}
+	}
+	return out
+}
+
+// Apply rotates the vector v
+func (m Matrix3) Apply(v [3]float64) [3]float64 {
+	var out [3]float64
+	for i := 0; i < 3; i++ {
+		out[i] = m[i][0]*v[0] + m[i][1]*v[1] + m[i][2]*v[2]
+	}
+	return out
+}
+
+// Transpose returns the transpose, which is the inverse of a rotation
+func (m Matrix3) Transpose() Matrix3 {
+	var out Matrix3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			out[i][j] = m[j][i]
+		}
+	}
+	return out
+}
+
+// RotVecToQuaternion converts a rotation vector to a unit quaternion
+func RotVecToQuaternion(rv [3]float64) Quaternion {
+	angle := math.Sqrt(rv[0]*rv[0] + rv[1]*rv[1] + rv[2]*rv[2])
+	if angle < 1e-12 {
+		return Quaternion{W: 1}
+	}
+	s := math.Sin(angle/2) / angle
+	return Quaternion{
+		W: math.Cos(angle / 2),
+		X: rv[0] * s,
+		Y: rv[1] * s,
+		Z: rv[2] * s,
+	}
+}
+
+// QuaternionToRotVec converts a quaternion to a rotation vector with an angle in [0, pi],
+// as URScript returns them
+func QuaternionToRotVec(q Quaternion) [3]float64 {
+	q = q.Normalize()
+	if q.W < 0 {
+		q = Quaternion{-q.W, -q.X, -q.Y, -q.Z}
+	}
+
+	n := math.Sqrt(q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+	if n < 1e-12 {
+		return [3]float64{}
+	}
+	angle := 2 * math.Atan2(n, q.W)
+	return [3]float64{q.X / n * angle, q.Y / n * angle, q.Z / n * angle}
+}
+
+// Normalize returns the quaternion scaled to unit length
+func (q Quaternion) Normalize() Quaternion {
+	n := math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+	if n == 0 {
+		return Quaternion{W: 1}
+	}
+	return Quaternion{q.W / n, q.X / n, q.Y / n, q.Z / n}
+}
+
+// Mul returns the Hamilton product q * r
+func (q Quaternion) Mul(r Quaternion) Quaternion {
+	return Quaternion{
+		W: q.W*r.W - q.X*r.X - q.Y*r.Y - q.Z*r.Z,
+		X: q.W*r.X + q.X*r.W + q.Y*r.Z - q.Z*r.Y,
+		Y: q.W*r.Y - q.X*r.Z + q.Y*r.W + q.Z*r.X,
+		Z: q.W*r.Z + q.X*r.Y - q.Y*r.X + q.Z*r.W,
+	}
+}
+
+// QuaternionToMatrix converts a quaternion to a rotation matrix
+func QuaternionToMatrix(q Quaternion) Matrix3 {
+	q = q.Normalize()
+	w, x, y, z := q.W, q.X, q.Y, q.Z
+	return Matrix3{
+		{1 - 2*(y*y+z*z), 2 * (x*y - z*w), 2 * (x*z + y*w)},
+		{2 * (x*y + z*w), 1 - 2*(x*x+z*z), 2 * (y*z - x*w)},
+		{2 * (x*z - y*w), 2 * (y*z + x*w), 1 - 2*(x*x+y*y)},
+	}
+}
+
+// MatrixToQuaternion converts a rotation matrix to a unit quaternion
+func MatrixToQuaternion(m Matrix3) Quaternion {
+	trace := m[0][0] + m[1][1] + m[2][2]
+	var q Quaternion
+	switch {
+	case trace > 0:
+		s := 2 * math.Sqrt(trace+1)
+		q = Quaternion{0.25 * s, (m[2][1] - m[1][2]) / s, (m[0][2] - m[2][0]) / s, (m[1][0] - m[0][1]) / s}
+	case m[0][0] > m[1][1] && m[0][0] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[0][0]-m[1][1]-m[2][2])
+		q = Quaternion{(m[2][1] - m[1][2]) / s, 0.25 * s, (m[0][1] + m[1][0]) / s, (m[0][2] + m[2][0]) / s}
+	case m[1][1] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[1][1]-m[0][0]-m[2][2])
+		q = Quaternion{(m[0][2] - m[2][0]) / s, (m[0][1] + m[1][0]) / s, 0.25 * s, (m[1][2] + m[2][1]) / s}
+	default:
+		s := 2 * math.Sqrt(1+m[2][2]-m[0][0]-m[1][1])
+		q = Quaternion{(m[1][0] - m[0][1]) / s, (m[0][2] + m[2][0]) / s, (m[1][2] + m[2][1]) / s, 0.25 * s}
+	}
+	return q.Normalize()
+}
+
+// RotVecToMatrix converts a rotation vector to a rotation matrix
+func RotVecToMatrix(rv [3]float64) Matrix3 {
+	return QuaternionToMatrix(RotVecToQuaternion(rv))
+}
+
+// MatrixToRotVec converts a rotation matrix to a rotation vector
+func MatrixToRotVec(m Matrix3) [3]float64 {
+	return QuaternionToRotVec(MatrixToQuaternion(m))
+}
+
+// RPYToMatrix converts roll, pitch and yaw (rotations about fixed X, Y and Z) to a
+// rotation matrix, R = Rz(yaw) * Ry(pitch) * Rx(roll), like URScript's rpy2rotvec
+func RPYToMatrix(rpy [3]float64) Matrix3 {
+	cr, sr := math.Cos(rpy[0]), math.Sin(rpy[0])
+	cp, sp := math.Cos(rpy[1]), math.Sin(rpy[1])
+	cy, sy := math.Cos(rpy[2]), math.Sin(rpy[2])
+	return Matrix3{
+		{cy * cp, cy*sp*sr - sy*cr, cy*sp*cr + sy*sr},
+		{sy * cp, sy*sp*sr + cy*cr, sy*sp*cr - cy*sr},
+		{-sp, cp * sr, cp * cr},
+	}
+}
+
+// MatrixToRPY converts a rotation matrix to roll, pitch and yaw
+func MatrixToRPY(m Matrix3) [3]float64 {
+	pitch := math.Atan2(-m[2][0], math.Hypot(m[0][0], m[1][0]))
+	if math.Abs(math.Cos(pitch)) < 1e-9 {
+		// Gimbal lock, fold the yaw into the roll
+		return [3]float64{math.Atan2(-m[1][2], m[1][1]), pitch, 0}
+	}
+	return [3]float64{
+		math.Atan2(m[2][1], m[2][2]),
+		pitch,
+		math.Atan2(m[1][0], m[0][0]),
+	}
+}
+
+// RPYToRotVec is the equivalent of URScript's rpy2rotvec
+func RPYToRotVec(rpy [3]float64) [3]float64 {
+	return MatrixToRotVec(RPYToMatrix(rpy))
+}
+
+// RotVecToRPY is the equivalent of URScript's rotvec2rpy
+func RotVecToRPY(rv [3]float64) [3]float64 {
+	return MatrixToRPY(RotVecToMatrix(rv))
+}
+
+// PoseTrans is the equivalent of URScript's pose_trans. It transforms pFromTo,
+// expressed in the frame pFrom, into the frame pFrom is expressed in.
+func PoseTrans(pFrom, pFromTo URPose) URPose {
+	return PoseFromMatrix(pFrom.Matrix().Mul(pFromTo.Matrix()))
+}
+
+// PoseInv is the equivalent of URScript's pose_inv
+func PoseInv(p URPose) URPose {
+	rt := RotVecToMatrix(p.RotVec()).Transpose()
+	t := rt.Apply(p.Position())
+	return NewPose([3]float64{-t[0], -t[1], -t[2]}, rt)
+}
+
+// PoseAdd is the equivalent of URScript's pose_add: positions are added and
+// the rotations are composed as R1 * R2
+func PoseAdd(p1, p2 URPose) URPose {
+	rot := RotVecToMatrix(p1.RotVec()).Mul(RotVecToMatrix(p2.RotVec()))
+	return NewPose([3]float64{p1[0] + p2[0], p1[1] + p2[1], p1[2] + p2[2]}, rot)
+}
+
+// PoseSub is the equivalent of URScript's pose_sub, the inverse of PoseAdd
+func PoseSub(pTo, pFrom URPose) URPose {
+	rot := RotVecToMatrix(pFrom.RotVec()).Transpose().Mul(RotVecToMatrix(pTo.RotVec()))
+	return NewPose([3]float64{pTo[0] - pFrom[0], pTo[1] - pFrom[1], pTo[2] - pFrom[2]}, rot)
+}
+
+// PoseDist is the equivalent of URScript's pose_dist, the euclidean distance
+// over all six pose components
+func PoseDist(pFrom, pTo URPose) float64 {
+	sum := 0.0
+	for i := range pFrom {
+		sum += (pTo[i] - pFrom[i]) * (pTo[i] - pFrom[i])
+	}
+	return math.Sqrt(sum)
+}
+
+// PointDist is the equivalent of URScript's point_dist, ignoring orientation
+func PointDist(pFrom, pTo URPose) float64 {
+	return math.Sqrt(math.Pow(pTo[0]-pFrom[0], 2) + math.Pow(pTo[1]-pFrom[1], 2) + math.Pow(pTo[2]-pFrom[2], 2))
+}
+
+// InterpolatePose is the equivalent of URScript's interpolate_pose. Position is
+// interpolated linearly and orientation along the shortest rotation.
+func InterpolatePose(pFrom, pTo URPose, alpha float64) (URPose, error) {
+	if alpha < 0 || alpha > 1 {
+		return URPose{}, fmt.Errorf("interpolation factor %f outside [0, 1]", alpha)
+	}
+
+	var pos [3]float64
+	for i := 0; i < 3; i++ {
+		pos[i] = pFrom[i] + (pTo[i]-pFrom[i])*alpha
+	}
+
+	q := slerp(RotVecToQuaternion(pFrom.RotVec()), RotVecToQuaternion(pTo.RotVec()), alpha)
+	rv := QuaternionToRotVec(q)
+	return URPose{pos[0], pos[1], pos[2], rv[0], rv[1], rv[2]}, nil
+}
+
+func slerp(a, b Quaternion, t float64) Quaternion {
+	dot := a.W*b.W + a.X*b.X + a.Y*b.Y + a.Z*b.Z
+	if dot < 0 {
+		b = Quaternion{-b.W, -b.X, -b.Y, -b.Z}
+		dot = -dot
+	}
+
+	if dot > 0.9995 {
+		return Quaternion{
+			a.W + (b.W-a.W)*t,
+			a.X + (b.X-a.X)*t,
+			a.Y + (b.Y-a.Y)*t,
+			a.Z + (b.Z-a.Z)*t,
+		}.Normalize()
+	}
+
+	theta := math.Acos(dot)
+	sa := math.Sin((1-t)*theta) / math.Sin(theta)
+	sb := math.Sin(t*theta) / math.Sin(theta)
+	return Quaternion{
+		a.W*sa + b.W*sb,
+		a.X*sa + b.X*sb,
+		a.Y*sa + b.Y*sb,
+		a.Z*sa + b.Z*sb,
+	}
+}
@@ -0,0 +1,192 @@
+package ur
+
+import (
+	"math"
+	"testing"
+)
+
+const poseTolerance = 1e-9
+
+// third is the rotation vector component of 120 degrees about (1, 1, 1)
+var third = 2 * math.Pi / 3 / math.Sqrt(3)
+
+// sameRotation compares rotation vectors by their matrices, so that the two
+// vectors of a half turn, rv and -rv, are equal
+func sameRotation(a, b [3]float64, tolerance float64) bool {
+	ma, mb := RotVecToMatrix(a), RotVecToMatrix(b)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if math.Abs(ma[i][j]-mb[i][j]) > tolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func samePose(a, b URPose, tolerance float64) bool {
+	for i := 0; i < 3; i++ {
+		if math.Abs(a[i]-b[i]) > tolerance {
+			return false
+		}
+	}
+	return sameRotation(a.RotVec(), b.RotVec(), tolerance)
+}
+
+func TestPoseTrans(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to URPose
+		want     URPose
+	}{
+		{"identity", URPose{}, URPose{0.1, 0.2, 0.3, 0.4, 0.5, 0.6}, URPose{0.1, 0.2, 0.3, 0.4, 0.5, 0.6}},
+		{"translation", URPose{1, 2, 3, 0, 0, 0}, URPose{0.5, 0, 0, 0, 0, 0}, URPose{1.5, 2, 3, 0, 0, 0}},
+		{"rotated frame", URPose{1, 0, 0, 0, 0, math.Pi / 2}, URPose{1, 0, 0, 0, 0, 0}, URPose{1, 1, 0, 0, 0, math.Pi / 2}},
+		{"tool offset", URPose{0, 0, 0.5, math.Pi, 0, 0}, URPose{0, 0, 0.1, 0, 0, 0}, URPose{0, 0, 0.4, math.Pi, 0, 0}},
+		{"composed rotations", URPose{0, 0, 0, math.Pi / 2, 0, 0}, URPose{0, 0, 0, 0, 0, math.Pi / 2}, URPose{0, 0, 0, third, -third, third}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PoseTrans(tt.from, tt.to)
+			if !samePose(got, tt.want, poseTolerance) {
+				t.Errorf("PoseTrans(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoseInv(t *testing.T) {
+	tests := []struct {
+		name string
+		pose URPose
+		want URPose
+	}{
+		{"identity", URPose{}, URPose{}},
+		{"translation", URPose{1, 2, 3, 0, 0, 0}, URPose{-1, -2, -3, 0, 0, 0}},
+		{"rotation about z", URPose{1, 0, 0, 0, 0, math.Pi / 2}, URPose{0, 1, 0, 0, 0, -math.Pi / 2}},
+		{"half turn", URPose{0, 0, 1, math.Pi, 0, 0}, URPose{0, 0, 1, math.Pi, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PoseInv(tt.pose)
+			if !samePose(got, tt.want, poseTolerance) {
+				t.Errorf("PoseInv(%v) = %v, want %v", tt.pose, got, tt.want)
+			}
+			if identity := PoseTrans(tt.pose, got); !samePose(identity, URPose{}, poseTolerance) {
+				t.Errorf("PoseTrans(p, PoseInv(p)) = %v, want identity", identity)
+			}
+		})
+	}
+}
+
+func TestPoseAddSub(t *testing.T) {
+	tests := []struct {
+		name   string
+		p1, p2 URPose
+		want   URPose
+	}{
+		{"translations", URPose{1, 2, 3, 0, 0, 0}, URPose{0.5, -1, 0, 0, 0, 0}, URPose{1.5, 1, 3, 0, 0, 0}},
+		{"rotations about one axis", URPose{0, 0, 0, 0, 0, math.Pi / 4}, URPose{0, 0, 0, 0, 0, math.Pi / 4}, URPose{0, 0, 0, 0, 0, math.Pi / 2}},
+		{"to a half turn", URPose{1, 2, 3, 0, 0, math.Pi / 2}, URPose{0.5, 0, 0, 0, 0, math.Pi / 2}, URPose{1.5, 2, 3, 0, 0, math.Pi}},
+		{"positions are not rotated", URPose{1, 0, 0, 0, 0, math.Pi / 2}, URPose{1, 0, 0, 0, 0, 0}, URPose{2, 0, 0, 0, 0, math.Pi / 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PoseAdd(tt.p1, tt.p2)
+			if !samePose(got, tt.want, poseTolerance) {
+				t.Errorf("PoseAdd(%v, %v) = %v, want %v", tt.p1, tt.p2, got, tt.want)
+			}
+			if back := PoseSub(got, tt.p1); !samePose(back, tt.p2, poseTolerance) {
+				t.Errorf("PoseSub(PoseAdd(p1, p2), p1) = %v, want %v", back, tt.p2)
+			}
+		})
+	}
+}
+
+func TestRPYToRotVec(t *testing.T) {
+	tests := []struct {
+		name string
+		rpy  [3]float64
+		want [3]float64
+	}{
+		{"zero", [3]float64{0, 0, 0}, [3]float64{0, 0, 0}},
+		{"roll", [3]float64{math.Pi / 2, 0, 0}, [3]float64{math.Pi / 2, 0, 0}},
+		{"pitch", [3]float64{0, math.Pi / 3, 0}, [3]float64{0, math.Pi / 3, 0}},
+		{"yaw", [3]float64{0, 0, -math.Pi / 2}, [3]float64{0, 0, -math.Pi / 2}},
+		{"half turn roll", [3]float64{math.Pi, 0, 0}, [3]float64{math.Pi, 0, 0}},
+		// Rz(90) * Rx(90) maps x to y, y to z and z to x: 120 degrees about (1, 1, 1)
+		{"roll and yaw", [3]float64{math.Pi / 2, 0, math.Pi / 2}, [3]float64{third, third, third}},
+		{"gimbal lock", [3]float64{0, math.Pi / 2, 0}, [3]float64{0, math.Pi / 2, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RPYToRotVec(tt.rpy)
+			if !sameRotation(got, tt.want, poseTolerance) {
+				t.Errorf("RPYToRotVec(%v) = %v, want %v", tt.rpy, got, tt.want)
+			}
+			if angle := math.Sqrt(got[0]*got[0] + got[1]*got[1] + got[2]*got[2]); angle > math.Pi+poseTolerance {
+				t.Errorf("RPYToRotVec(%v) has angle %f, want at most pi", tt.rpy, angle)
+			}
+		})
+	}
+}
+
+func TestRotVecRPYRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		rv   [3]float64
+	}{
+		{"zero", [3]float64{0, 0, 0}},
+		{"small", [3]float64{1e-10, -2e-10, 1e-10}},
+		{"generic", [3]float64{0.3, -1.2, 0.8}},
+		{"half turn about x", [3]float64{math.Pi, 0, 0}},
+		{"half turn about a diagonal", [3]float64{math.Pi / math.Sqrt2, math.Pi / math.Sqrt2, 0}},
+		{"nearly a half turn", [3]float64{0, 0, math.Pi - 1e-9}},
+		{"gimbal lock", [3]float64{0, math.Pi / 2, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rpy := RotVecToRPY(tt.rv)
+			got := RPYToRotVec(rpy)
+			if !sameRotation(got, tt.rv, 1e-8) {
+				t.Errorf("RPYToRotVec(RotVecToRPY(%v)) = %v via rpy %v", tt.rv, got, rpy)
+			}
+		})
+	}
+}
+
+func TestQuaternionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		rv   [3]float64
+		want [3]float64 // the canonical vector, with an angle in [0, pi]
+	}{
+		{"zero", [3]float64{0, 0, 0}, [3]float64{0, 0, 0}},
+		{"small", [3]float64{1e-13, 0, 0}, [3]float64{0, 0, 0}},
+		{"generic", [3]float64{0.3, -1.2, 0.8}, [3]float64{0.3, -1.2, 0.8}},
+		{"half turn", [3]float64{0, math.Pi, 0}, [3]float64{0, math.Pi, 0}},
+		{"more than a half turn", [3]float64{0, 0, 3 * math.Pi / 2}, [3]float64{0, 0, -math.Pi / 2}},
+		{"full turn", [3]float64{2 * math.Pi, 0, 0}, [3]float64{0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := QuaternionToRotVec(RotVecToQuaternion(tt.rv))
+			for i := range got {
+				if math.Abs(got[i]-tt.want[i]) > poseTolerance {
+					t.Fatalf("QuaternionToRotVec(RotVecToQuaternion(%v)) = %v, want %v", tt.rv, got, tt.want)
+				}
+			}
+
+			fromMatrix := MatrixToRotVec(RotVecToMatrix(tt.rv))
+			if !sameRotation(fromMatrix, tt.want, poseTolerance) {
+				t.Errorf("MatrixToRotVec(RotVecToMatrix(%v)) = %v, want %v", tt.rv, fromMatrix, tt.want)
+			}
+		})
+	}
+}
@@ -70,3 +70,21 @@ func (p PositionMap) ToMap() map[string]URPosition {
 		"pick_letter": p.PickLetter,
 	}
 }
+
+// ToStore converts the map to a position store, so the fixed cell layout
+// can be used as a preset for the named store
+func (p PositionMap) ToStore() (*PositionStore, error) {
+	store := NewPositionStore()
+	for name, pos := range p.ToMap() {
+		err := store.Set(StoredPosition{
+			Name:   name,
+			Kind:   POSITION_JOINTS,
+			Units:  UNIT_RAD,
+			Values: pos,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
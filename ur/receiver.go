@@ -4,33 +4,67 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
-	"net"
+	"strings"
+	"sync"
+	"time"
 )
 
 type URReceiver struct {
 	*URCommon
+	requestMu sync.Mutex
+
+	packetMu   sync.Mutex
+	packetConn *connection // connection the pending bytes were read from
+	pending    []byte      // start of a package that has not fully arrived
+	streams    map[packetKey]*packetStream
+	arrived    chan struct{} // closed and replaced whenever packages arrive
+}
+
+// anyRecipe matches data packages of every recipe
+const anyRecipe = -1
+
+// packetKey identifies a stream of packages: a command and, for data packages, a recipe ID
+type packetKey struct {
+	command uint8
+	recipe  int
+}
+
+// packetStream holds the newest package of a stream and how many have arrived
+type packetStream struct {
+	seq    uint64
+	packet []byte // whole package including the header
 }
 
 func NewReceiver(ctx context.Context, cfg URConfig) *URReceiver {
-	return &URReceiver{
+	r := &URReceiver{
 		URCommon: &URCommon{
 			Ctx: ctx,
 			cfg: cfg,
 		},
+		streams: make(map[packetKey]*packetStream),
+		arrived: make(chan struct{}),
 	}
+	r.route = r.routePackets
+	return r
 }
 
 func (r *URReceiver) Connect() error {
-	err := r.URCommon.Connect()
+	return r.ConnectContext(r.Ctx)
+}
+
+// ConnectContext connects and negotiates the protocol version within ctx
+func (r *URReceiver) ConnectContext(ctx context.Context) error {
+	err := r.URCommon.ConnectContext(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Send the RTDE handshake
-	ok, err := r.negotiateProtocolVersion2()
+	ok, err := r.negotiateProtocolVersion2(ctx)
 	if err != nil || !ok {
+		r.Disconnect()
 		return fmt.Errorf("failed to negotiate protocol version: %v", err)
 	}
 
@@ -38,20 +72,18 @@ func (r *URReceiver) Connect() error {
 }
 
 func (r *URReceiver) StartDataExchange() error {
-	payload := r.createPayload(RTDE_CONTROL_PACKAGE_START, nil)
+	return r.StartDataExchangeContext(r.Ctx)
+}
 
-	_, err := r.conn.Write(payload)
-	if err != nil {
-		return err
-	}
+func (r *URReceiver) StartDataExchangeContext(ctx context.Context) error {
+	payload := r.createPayload(RTDE_CONTROL_PACKAGE_START, nil)
 
-	response := make([]byte, 1024)
-	n, err := r.conn.Read(response)
+	response, err := r.exchange(ctx, payload)
 	if err != nil {
 		return err
 	}
 
-	if n < 3 {
+	if len(response) < 4 {
 		return fmt.Errorf("invalid response: too short")
 	}
 
@@ -64,37 +96,38 @@ func (r *URReceiver) StartDataExchange() error {
 }
 
 func (r *URReceiver) Listen(command uint8) ([]byte, error) {
-	resp, err := r.recvToBuffer(r.conn)
+	return r.ListenContext(r.Ctx, command)
+}
+
+// ListenContext waits for the next package of the given command until ctx is done.
+// Every caller receives every package; a caller that falls behind gets the newest one.
+func (r *URReceiver) ListenContext(ctx context.Context, command uint8) ([]byte, error) {
+	key := packetKey{command: command, recipe: anyRecipe}
+	packet, err := r.next(ctx, key, r.sequence(key))
 	if err != nil {
 		return nil, err
 	}
+	if len(packet) < 4 {
+		return nil, fmt.Errorf("invalid package: too short")
+	}
+	return packet[4:], nil
+}
 
-	for len(resp) >= 3 {
-		packetHeader := UnpackControlHeader(resp)
-
-		if len(resp) >= int(packetHeader.Size) {
-			packet := resp[3:packetHeader.Size]
-			resp = resp[packetHeader.Size:]
-
-			if len(resp) >= int(packetHeader.Size) {
-				nextPacketHeader := UnpackControlHeader(resp)
-				if nextPacketHeader.Command == command {
-					log.Println("Skipping package (1)")
-					continue
-				}
-			}
+// ListenRecipe waits for the next data package of the given output recipe
+func (r *URReceiver) ListenRecipe(recipeID uint8) ([]byte, error) {
+	return r.ListenRecipeContext(r.Ctx, recipeID)
+}
 
-			if packetHeader.Command == command {
-				return packet[1:], nil
-			} else {
-				log.Println("Skipping package (2)")
-			}
-		} else {
-			break
-		}
+func (r *URReceiver) ListenRecipeContext(ctx context.Context, recipeID uint8) ([]byte, error) {
+	key := packetKey{command: RTDE_DATA_PACKAGE, recipe: int(recipeID)}
+	packet, err := r.next(ctx, key, r.sequence(key))
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, fmt.Errorf("recv() Connection lost")
+	if len(packet) < 4 {
+		return nil, fmt.Errorf("invalid package: too short")
+	}
+	return packet[4:], nil
 }
 
 type OutputRequest struct {
@@ -120,27 +153,26 @@ type OutputResponse struct {
 }
 
 func (r *URReceiver) SendOutputSetup(vars string) (*OutputResponse, error) {
+	return r.SendOutputSetupContext(r.Ctx, vars)
+}
+
+func (r *URReceiver) SendOutputSetupContext(ctx context.Context, vars string) (*OutputResponse, error) {
 	req := &OutputRequest{
 		Header: &Header{
 			PkgSize: 0,
 			Cmd:     RTDE_CONTROL_PACKAGE_SETUP_OUTPUTS,
 		},
-		Freq: MAX_FREQ,
+		Freq: r.frequency(),
 		Vars: vars,
 	}
 	payload := r.createPayload(RTDE_CONTROL_PACKAGE_SETUP_OUTPUTS, req.ToBytes())
 
-	_, err := r.conn.Write(payload)
-	if err != nil {
-		return nil, err
-	}
-
-	response := make([]byte, 4096)
-	n, err := r.conn.Read(response)
+	response, err := r.exchange(ctx, payload)
 	if err != nil {
 		return nil, err
 	}
 
+	n := len(response)
 	if n < 4 {
 		return nil, fmt.Errorf("invalid response: too short")
 	}
@@ -152,6 +184,7 @@ func (r *URReceiver) SendOutputSetup(vars string) (*OutputResponse, error) {
 
 	recipeID := response[3]
 	types := string(response[4:n])
+	r.recordAudit(AuditEntry{Kind: AUDIT_RTDE_SETUP, Text: "outputs: " + vars, Metadata: map[string]string{"types": types}})
 
 	return &OutputResponse{
 		Header:   &header,
@@ -160,22 +193,157 @@ func (r *URReceiver) SendOutputSetup(vars string) (*OutputResponse, error) {
 	}, nil
 }
 
-// negotiateProtocolVersion2 sends the RTDE_REQUEST_PROTOCOL_VERSION message and handles the response
-func (r *URReceiver) negotiateProtocolVersion2() (bool, error) {
-	payload := r.createRTDEProtocolRequest(RTDE_PROTOCOL_VERSION_2)
+// SendInputSetup configures an RTDE input recipe with the given comma separated variables
+func (r *URReceiver) SendInputSetup(vars string) (*DataConfig, error) {
+	return r.SendInputSetupContext(r.Ctx, vars)
+}
+
+func (r *URReceiver) SendInputSetupContext(ctx context.Context, vars string) (*DataConfig, error) {
+	payload := r.createPayload(RTDE_CONTROL_PACKAGE_SETUP_INPUTS, []byte(vars))
 
-	_, err := r.conn.Write(payload)
+	response, err := r.exchange(ctx, payload)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	n := len(response)
+	if n < 4 {
+		return nil, fmt.Errorf("invalid response: too short")
+	}
+
+	types := string(response[4:n])
+	if strings.Contains(types, "IN_USE") || strings.Contains(types, "NOT_FOUND") {
+		err := fmt.Errorf("input setup rejected for %q: %s", vars, types)
+		r.recordAudit(AuditEntry{Kind: AUDIT_RTDE_SETUP, Text: "inputs: " + vars, Error: err.Error()})
+		return nil, err
+	}
+	r.recordAudit(AuditEntry{Kind: AUDIT_RTDE_SETUP, Text: "inputs: " + vars, Metadata: map[string]string{"types": types}})
+
+	return &DataConfig{
+		ID:    response[3],
+		Names: strings.Split(vars, ","),
+		Types: strings.Split(types, ","),
+	}, nil
+}
+
+// SendInputs writes the fields of obj to the robot using an input recipe from SendInputSetup
+func (r *URReceiver) SendInputs(cfg *DataConfig, obj *DataObject) error {
+	return r.SendInputsContext(r.Ctx, cfg, obj)
+}
+
+func (r *URReceiver) SendInputsContext(ctx context.Context, cfg *DataConfig, obj *DataObject) error {
+	obj.RecipeID = cfg.ID
+	data, err := cfg.Pack(obj)
+	if err != nil {
+		return err
 	}
 
-	response := make([]byte, 1024)
-	n, err := r.conn.Read(response)
+	return r.writeBytes(ctx, r.createPayload(RTDE_DATA_PACKAGE, data))
+}
+
+// GetControllerVersion requests the URControl version of the controller
+func (r *URReceiver) GetControllerVersion() (ControlVersion, error) {
+	return r.GetControllerVersionContext(r.Ctx)
+}
+
+func (r *URReceiver) GetControllerVersionContext(ctx context.Context) (ControlVersion, error) {
+	payload := r.createPayload(RTDE_GET_URCONTROL_VERSION, nil)
+
+	response, err := r.exchange(ctx, payload)
+	if err != nil {
+		return ControlVersion{}, err
+	}
+
+	if len(response) < 19 {
+		return ControlVersion{}, fmt.Errorf("invalid response: too short")
+	}
+
+	return UnpackControlVersion(response[3:]), nil
+}
+
+// DetectModel resolves the robot model from the given robot type (e.g. "UR5")
+// and the controller version, and stores it on the receiver
+func (r *URReceiver) DetectModel(robotType string) (*URModel, error) {
+	version, err := r.GetControllerVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	model, err := DetectModel(robotType, version)
+	if err != nil {
+		return nil, err
+	}
+
+	r.SetModel(model)
+	return model, nil
+}
+
+var textMessageLevels = map[uint8]string{
+	ExceptionMessage: "exception",
+	ErrorMessage:     "error",
+	WarningMessage:   "warning",
+	InfoMessage:      "info",
+}
+
+// textMessageLogLevel maps a text message level to the level it is logged at
+func textMessageLogLevel(level uint8) slog.Level {
+	switch level {
+	case ExceptionMessage, ErrorMessage:
+		return slog.LevelError
+	case WarningMessage:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// handleTextMessage logs a text message sent by the controller and records it in the audit log
+func (r *URReceiver) handleTextMessage(packet []byte) {
+	if !validMessage(packet) {
+		slog.Warn("Skipping invalid text message", "size", len(packet))
+		return
+	}
+
+	msg := UnpackMessage(packet)
+	level, ok := textMessageLevels[msg.Level]
+	if !ok {
+		level = fmt.Sprintf("level %d", msg.Level)
+	}
+
+	slog.Log(context.Background(), textMessageLogLevel(msg.Level), "Robot "+level, "source", msg.Source, "message", msg.Message)
+	r.recordAudit(AuditEntry{Kind: AUDIT_TEXT_MESSAGE, Text: msg.Message, Level: level, Metadata: map[string]string{"source": msg.Source}})
+}
+
+// validMessage checks that the lengths in a text message fit the buffer
+func validMessage(buf []byte) bool {
+	if len(buf) < 1 {
+		return false
+	}
+	srcOffset := 1 + int(buf[0])
+	if len(buf) < srcOffset+1 {
+		return false
+	}
+	return len(buf) >= srcOffset+1+int(buf[srcOffset])+1
+}
+
+// frequency returns the output frequency supported by the robot model
+func (r *URReceiver) frequency() float64 {
+	if model := r.Model(); model != nil {
+		return model.MaxRTDEFrequency
+	}
+	return MAX_FREQ
+}
+
+// negotiateProtocolVersion2 sends the RTDE_REQUEST_PROTOCOL_VERSION message and handles the response
+func (r *URReceiver) negotiateProtocolVersion2(ctx context.Context) (bool, error) {
+	payload := r.createRTDEProtocolRequest(RTDE_PROTOCOL_VERSION_2)
+
+	response, err := r.exchange(ctx, payload)
 	if err != nil {
 		return false, err
 	}
 
-	if n < 3 {
+	if len(response) < 4 {
 		return false, fmt.Errorf("invalid response: too short")
 	}
 
@@ -183,17 +351,132 @@ func (r *URReceiver) negotiateProtocolVersion2() (bool, error) {
 	return accepted, nil
 }
 
-func (r *URReceiver) recvToBuffer(conn net.Conn) ([]byte, error) {
-	buf := make([]byte, 4096)
-	n, err := conn.Read(buf)
-	if err != nil {
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			return nil, fmt.Errorf("timeout reading from socket")
+// exchange writes a request and waits for the reply with the same command within ctx.
+// Requests are serialised so concurrent callers do not receive each other's replies.
+func (r *URReceiver) exchange(ctx context.Context, payload []byte) ([]byte, error) {
+	r.requestMu.Lock()
+	defer r.requestMu.Unlock()
+
+	key := packetKey{command: payload[2], recipe: anyRecipe}
+	seq := r.sequence(key)
+	if err := r.writeBytes(ctx, payload); err != nil {
+		return nil, err
+	}
+	return r.next(ctx, key, seq)
+}
+
+// routePackets splits the received bytes into RTDE packages and publishes them by command
+// and recipe ID. It runs on the reader goroutine.
+func (r *URReceiver) routePackets(cn *connection, data []byte) {
+	var messages [][]byte
+
+	r.packetMu.Lock()
+	if r.packetConn != cn {
+		// Packages of the previous connection are stale
+		r.packetConn = cn
+		r.pending = nil
+		for _, s := range r.streams {
+			s.packet = nil
+		}
+	}
+
+	r.pending = append(r.pending, data...)
+	published := false
+	for len(r.pending) >= 3 {
+		header := UnpackControlHeader(r.pending)
+		size := int(header.Size)
+		if size < 3 {
+			slog.Warn("Dropping invalid RTDE data", "size", size)
+			r.pending = nil
+			break
+		}
+		if len(r.pending) < size {
+			break
 		}
+
+		packet := append([]byte(nil), r.pending[:size]...)
+		r.pending = r.pending[size:]
+
+		if header.Command == RTDE_TEXT_MESSAGE {
+			messages = append(messages, packet[3:])
+		}
+		r.publish(packetKey{command: header.Command, recipe: anyRecipe}, packet)
+		if header.Command == RTDE_DATA_PACKAGE && size > 3 {
+			r.publish(packetKey{command: header.Command, recipe: int(packet[3])}, packet)
+		}
+		published = true
+	}
+	if published {
+		close(r.arrived)
+		r.arrived = make(chan struct{})
+	}
+	r.packetMu.Unlock()
+
+	for _, msg := range messages {
+		r.handleTextMessage(msg)
+	}
+}
+
+// publish makes packet the newest package of its stream. The caller holds packetMu.
+func (r *URReceiver) publish(key packetKey, packet []byte) {
+	s, ok := r.streams[key]
+	if !ok {
+		s = &packetStream{}
+		r.streams[key] = s
+	}
+	s.seq++
+	s.packet = packet
+}
+
+// sequence returns how many packages of the stream have arrived
+func (r *URReceiver) sequence(key packetKey) uint64 {
+	r.packetMu.Lock()
+	defer r.packetMu.Unlock()
+
+	if s, ok := r.streams[key]; ok {
+		return s.seq
+	}
+	return 0
+}
+
+// next waits for a package of the stream newer than seq. It waits until ctx is done, or
+// for URConfig.Timeout when ctx has no deadline.
+func (r *URReceiver) next(ctx context.Context, key packetKey, seq uint64) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cn, err := r.connection()
+	if err != nil {
 		return nil, err
 	}
 
-	return buf[:n], nil
+	var timeout <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok && r.cfg.Timeout > 0 {
+		timer := time.NewTimer(r.cfg.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		r.packetMu.Lock()
+		if s, ok := r.streams[key]; ok && s.seq > seq && s.packet != nil {
+			packet := s.packet
+			r.packetMu.Unlock()
+			return packet, nil
+		}
+		arrived := r.arrived
+		r.packetMu.Unlock()
+
+		select {
+		case <-arrived:
+		case <-cn.closed:
+			return nil, fmt.Errorf("connection to robot closed: %v", cn.err)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout:
+			return nil, fmt.Errorf("timeout reading from socket")
+		}
+	}
 }
 
 func (r *URReceiver) createPayload(pkgType uint8, payload []byte) []byte {
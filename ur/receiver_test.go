@@ -0,0 +1,253 @@
+package ur
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// rtdePackage frames a payload as an RTDE package
+func rtdePackage(command uint8, payload ...byte) []byte {
+	size := 3 + len(payload)
+	return append([]byte{byte(size >> 8), byte(size), command}, payload...)
+}
+
+func concat(chunks ...[]byte) []byte {
+	var out []byte
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	return out
+}
+
+// pipeReceiver returns a receiver reading from one end of a pipe and the other end
+func pipeReceiver(t *testing.T) (*URReceiver, net.Conn) {
+	t.Helper()
+
+	client, server := net.Pipe()
+	r := NewReceiver(context.Background(), URConfig{Timeout: time.Second})
+	cn := &connection{
+		Conn:   client,
+		id:     "test",
+		reads:  make(chan []byte, 64),
+		closed: make(chan struct{}),
+	}
+	r.conn = cn
+	go r.readLoop(cn)
+
+	t.Cleanup(func() {
+		server.Close()
+		r.Disconnect()
+	})
+	return r, server
+}
+
+func TestRoutePackets(t *testing.T) {
+	data1 := rtdePackage(RTDE_DATA_PACKAGE, 1, 0xAA)
+	data2 := rtdePackage(RTDE_DATA_PACKAGE, 2, 0xBB, 0xCC)
+	data1b := rtdePackage(RTDE_DATA_PACKAGE, 1, 0xDD)
+	start := rtdePackage(RTDE_CONTROL_PACKAGE_START, 1)
+
+	type want struct {
+		key    packetKey
+		seq    uint64
+		packet []byte
+	}
+
+	tests := []struct {
+		name   string
+		chunks [][]byte
+		want   []want
+	}{
+		{
+			name:   "one package per chunk",
+			chunks: [][]byte{data1, start},
+			want: []want{
+				{packetKey{RTDE_DATA_PACKAGE, 1}, 1, data1},
+				{packetKey{RTDE_DATA_PACKAGE, anyRecipe}, 1, data1},
+				{packetKey{RTDE_CONTROL_PACKAGE_START, anyRecipe}, 1, start},
+			},
+		},
+		{
+			name:   "combined packages",
+			chunks: [][]byte{concat(data1, data2, data1b)},
+			want: []want{
+				{packetKey{RTDE_DATA_PACKAGE, 1}, 2, data1b},
+				{packetKey{RTDE_DATA_PACKAGE, 2}, 1, data2},
+				{packetKey{RTDE_DATA_PACKAGE, anyRecipe}, 3, data1b},
+			},
+		},
+		{
+			name:   "split payload",
+			chunks: [][]byte{data2[:4], data2[4:]},
+			want: []want{
+				{packetKey{RTDE_DATA_PACKAGE, 2}, 1, data2},
+			},
+		},
+		{
+			name:   "split header",
+			chunks: [][]byte{data2[:1], data2[1:3], concat(data2[3:], start[:2]), start[2:]},
+			want: []want{
+				{packetKey{RTDE_DATA_PACKAGE, 2}, 1, data2},
+				{packetKey{RTDE_CONTROL_PACKAGE_START, anyRecipe}, 1, start},
+			},
+		},
+		{
+			name:   "incomplete package",
+			chunks: [][]byte{data1, data2[:5]},
+			want: []want{
+				{packetKey{RTDE_DATA_PACKAGE, 1}, 1, data1},
+				{packetKey{RTDE_DATA_PACKAGE, 2}, 0, nil},
+			},
+		},
+		{
+			name:   "invalid size drops the buffer",
+			chunks: [][]byte{{0, 1, RTDE_DATA_PACKAGE}, data1},
+			want: []want{
+				{packetKey{RTDE_DATA_PACKAGE, 1}, 1, data1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReceiver(context.Background(), URConfig{})
+			cn := &connection{closed: make(chan struct{})}
+			for _, chunk := range tt.chunks {
+				r.routePackets(cn, chunk)
+			}
+
+			for _, w := range tt.want {
+				if seq := r.sequence(w.key); seq != w.seq {
+					t.Errorf("sequence(%v) = %d, want %d", w.key, seq, w.seq)
+				}
+				var packet []byte
+				if s, ok := r.streams[w.key]; ok {
+					packet = s.packet
+				}
+				if !bytes.Equal(packet, w.packet) {
+					t.Errorf("packet(%v) = %v, want %v", w.key, packet, w.packet)
+				}
+			}
+		})
+	}
+}
+
+func TestRoutePacketsNewConnection(t *testing.T) {
+	r := NewReceiver(context.Background(), URConfig{})
+	first := &connection{closed: make(chan struct{})}
+	second := &connection{closed: make(chan struct{})}
+
+	data := rtdePackage(RTDE_DATA_PACKAGE, 1, 0xAA)
+	r.routePackets(first, concat(data, data[:2]))
+	r.routePackets(second, data)
+
+	key := packetKey{RTDE_DATA_PACKAGE, 1}
+	if seq := r.sequence(key); seq != 2 {
+		t.Errorf("sequence = %d, want 2: the partial package of the first connection must be dropped", seq)
+	}
+	if packet := r.streams[key].packet; !bytes.Equal(packet, data) {
+		t.Errorf("packet = %v, want %v", packet, data)
+	}
+}
+
+func TestNextWaitsForNewerPackage(t *testing.T) {
+	r, server := pipeReceiver(t)
+
+	stale := rtdePackage(RTDE_DATA_PACKAGE, 1, 0x01)
+	if _, err := server.Write(stale); err != nil {
+		t.Fatal(err)
+	}
+	// Wait until the stale package has been routed
+	key := packetKey{RTDE_DATA_PACKAGE, 1}
+	for r.sequence(key) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	got, err := r.next(context.Background(), key, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, stale) {
+		t.Errorf("next(0) = %v, want %v", got, stale)
+	}
+
+	fresh := rtdePackage(RTDE_DATA_PACKAGE, 1, 0x03)
+	go server.Write(concat(rtdePackage(RTDE_DATA_PACKAGE, 2, 0x02), fresh))
+
+	got, err = r.next(context.Background(), key, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, fresh) {
+		t.Errorf("next(1) = %v, want %v", got, fresh)
+	}
+}
+
+func TestExchangeSkipsOtherPackages(t *testing.T) {
+	r, server := pipeReceiver(t)
+
+	go func() {
+		request := make([]byte, 3)
+		if _, err := server.Read(request); err != nil || request[2] != RTDE_CONTROL_PACKAGE_START {
+			return
+		}
+		server.Write(concat(
+			rtdePackage(RTDE_DATA_PACKAGE, 1, 0x00),
+			rtdePackage(RTDE_CONTROL_PACKAGE_START, 1),
+		))
+	}()
+
+	if err := r.StartDataExchange(); err != nil {
+		t.Fatalf("StartDataExchange: %v", err)
+	}
+}
+
+func TestListenConnectionClosed(t *testing.T) {
+	r, server := pipeReceiver(t)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		server.Close()
+	}()
+
+	if _, err := r.ListenRecipe(1); err == nil {
+		t.Fatal("ListenRecipe returned no error after the connection closed")
+	}
+	if r.IsConnected() {
+		t.Error("IsConnected() = true after the connection closed")
+	}
+}
+
+func TestReadLoopNoticesCloseWithoutReader(t *testing.T) {
+	client, server := net.Pipe()
+	c := &URCommon{Ctx: context.Background(), cfg: URConfig{Timeout: time.Second}}
+	cn := &connection{
+		Conn:   client,
+		id:     "test",
+		reads:  make(chan []byte, 64),
+		closed: make(chan struct{}),
+	}
+	c.conn = cn
+	go c.readLoop(cn)
+
+	// Far more data than the read queue holds, and nobody calls read
+	chunk := make([]byte, 4096)
+	for i := 0; i < 200; i++ {
+		if _, err := server.Write(chunk); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+	server.Close()
+
+	select {
+	case <-cn.closed:
+	case <-time.After(time.Second):
+		t.Fatal("closed connection not noticed")
+	}
+	if c.IsConnected() {
+		t.Error("IsConnected() = true after the connection closed")
+	}
+}
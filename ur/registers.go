@@ -0,0 +1,242 @@
+package ur
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RegisterType is the type of a general purpose register
+type RegisterType string
+
+const (
+	REGISTER_INT    RegisterType = "int"
+	REGISTER_DOUBLE RegisterType = "double"
+	REGISTER_BIT    RegisterType = "bit"
+)
+
+// RegisterDirection is seen from the robot: inputs are written by Go, outputs by the robot program
+type RegisterDirection string
+
+const (
+	REGISTER_INPUT  RegisterDirection = "input"
+	REGISTER_OUTPUT RegisterDirection = "output"
+)
+
+// FIELDBUS_REGISTERS is the number of int and double registers, starting at 0, that
+// fieldbus adapters such as PLC, EtherNet/IP and PROFINET use. Allocate skips them.
+const FIELDBUS_REGISTERS = 24
+
+// registerRange returns the valid indices of a register type
+func registerRange(typ RegisterType) (int, int, error) {
+	switch typ {
+	case REGISTER_INT, REGISTER_DOUBLE:
+		return 0, 47, nil
+	case REGISTER_BIT:
+		return 64, 127, nil
+	default:
+		return 0, 0, fmt.Errorf("unknown register type: %q", typ)
+	}
+}
+
+// Register is a general purpose register slot owned by a component
+type Register struct {
+	Name      string
+	Owner     string
+	Type      RegisterType
+	Direction RegisterDirection
+	Index     int
+}
+
+// Variable returns the RTDE variable name, e.g. input_int_register_24
+func (r Register) Variable() string {
+	return fmt.Sprintf("%s_%s_register_%d", r.Direction, r.Type, r.Index)
+}
+
+// RTDEType returns the RTDE type of the register
+func (r Register) RTDEType() string {
+	switch r.Type {
+	case REGISTER_INT:
+		return "INT32"
+	case REGISTER_DOUBLE:
+		return TYPE_DOUBLE
+	default:
+		return "BOOL"
+	}
+}
+
+// scriptType returns the URScript name of the register type
+func (r Register) scriptType() string {
+	switch r.Type {
+	case REGISTER_INT:
+		return "integer"
+	case REGISTER_DOUBLE:
+		return "float"
+	default:
+		return "boolean"
+	}
+}
+
+// ReadScript returns the URScript expression reading the register
+func (r Register) ReadScript() string {
+	return fmt.Sprintf("read_%s_%s_register(%d)", r.Direction, r.scriptType(), r.Index)
+}
+
+// WriteScript returns the URScript statement writing value to an output register
+func (r Register) WriteScript(value string) (string, error) {
+	if r.Direction != REGISTER_OUTPUT {
+		return "", fmt.Errorf("register %s is not writable from URScript", r.Variable())
+	}
+	return fmt.Sprintf("write_output_%s_register(%d, %s)", r.scriptType(), r.Index, value), nil
+}
+
+func (r Register) String() string {
+	return fmt.Sprintf("%s (%s/%s)", r.Variable(), r.Owner, r.Name)
+}
+
+type registerKey struct {
+	typ   RegisterType
+	dir   RegisterDirection
+	index int
+}
+
+// RegisterManager hands out general purpose registers to components and
+// reports when two components claim the same slot
+type RegisterManager struct {
+	mu        sync.Mutex
+	registers map[registerKey]Register
+}
+
+func NewRegisterManager() *RegisterManager {
+	return &RegisterManager{
+		registers: make(map[registerKey]Register),
+	}
+}
+
+// Reserve claims a specific register
+func (m *RegisterManager) Reserve(owner, name string, typ RegisterType, dir RegisterDirection, index int) (Register, error) {
+	lo, hi, err := registerRange(typ)
+	if err != nil {
+		return Register{}, err
+	}
+	if index < lo || index > hi {
+		return Register{}, fmt.Errorf("%s register index %d out of range [%d, %d]", typ, index, lo, hi)
+	}
+	if dir != REGISTER_INPUT && dir != REGISTER_OUTPUT {
+		return Register{}, fmt.Errorf("unknown register direction: %q", dir)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reserve(owner, name, typ, dir, index)
+}
+
+// reserve claims a register at a valid index. The caller holds mu.
+func (m *RegisterManager) reserve(owner, name string, typ RegisterType, dir RegisterDirection, index int) (Register, error) {
+	key := registerKey{typ, dir, index}
+	reg := Register{Name: name, Owner: owner, Type: typ, Direction: dir, Index: index}
+	if existing, taken := m.registers[key]; taken {
+		if existing.Owner == owner && existing.Name == name {
+			return existing, nil
+		}
+		return Register{}, fmt.Errorf("register collision: %s wanted by %s/%s is owned by %s/%s", reg.Variable(), owner, name, existing.Owner, existing.Name)
+	}
+
+	m.registers[key] = reg
+	return reg, nil
+}
+
+// Allocate claims the lowest free register of the given type and direction.
+// Int and double registers used by fieldbus adapters are never allocated.
+func (m *RegisterManager) Allocate(owner, name string, typ RegisterType, dir RegisterDirection) (Register, error) {
+	lo, hi, err := registerRange(typ)
+	if err != nil {
+		return Register{}, err
+	}
+	if dir != REGISTER_INPUT && dir != REGISTER_OUTPUT {
+		return Register{}, fmt.Errorf("unknown register direction: %q", dir)
+	}
+	if typ == REGISTER_INT || typ == REGISTER_DOUBLE {
+		lo = FIELDBUS_REGISTERS
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	free := -1
+	for i := lo; i <= hi; i++ {
+		existing, taken := m.registers[registerKey{typ, dir, i}]
+		if taken && existing.Owner == owner && existing.Name == name {
+			return existing, nil
+		}
+		if !taken && free < 0 {
+			free = i
+		}
+	}
+
+	if free < 0 {
+		return Register{}, fmt.Errorf("no free %s %s registers", dir, typ)
+	}
+	return m.reserve(owner, name, typ, dir, free)
+}
+
+// Release frees all registers of an owner
+func (m *RegisterManager) Release(owner string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, reg := range m.registers {
+		if reg.Owner == owner {
+			delete(m.registers, key)
+		}
+	}
+}
+
+// Registers returns the registers of an owner, or all registers when owner is empty,
+// sorted by direction, type and index
+func (m *RegisterManager) Registers(owner string) []Register {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var regs []Register
+	for _, reg := range m.registers {
+		if owner == "" || reg.Owner == owner {
+			regs = append(regs, reg)
+		}
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		if regs[i].Direction != regs[j].Direction {
+			return regs[i].Direction < regs[j].Direction
+		}
+		if regs[i].Type != regs[j].Type {
+			return regs[i].Type < regs[j].Type
+		}
+		return regs[i].Index < regs[j].Index
+	})
+	return regs
+}
+
+// Recipe returns the RTDE recipe for the owner's registers in one direction.
+// Input registers go in an input setup, output registers in an output setup.
+func (m *RegisterManager) Recipe(owner string, dir RegisterDirection) string {
+	var vars []string
+	for _, reg := range m.Registers(owner) {
+		if reg.Direction == dir {
+			vars = append(vars, reg.Variable())
+		}
+	}
+	return strings.Join(vars, ",")
+}
+
+// ScriptSnippet returns URScript statements that read the owner's input registers
+// into variables named after the registers
+func (m *RegisterManager) ScriptSnippet(owner string) string {
+	var lines []string
+	for _, reg := range m.Registers(owner) {
+		if reg.Direction == REGISTER_INPUT {
+			lines = append(lines, fmt.Sprintf("%s = %s", reg.Name, reg.ReadScript()))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
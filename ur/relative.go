@@ -0,0 +1,101 @@
+package ur
+
+import (
+	"context"
+	"fmt"
+)
+
+// RelativeFrame is the frame a relative move offset is expressed in
+type RelativeFrame string
+
+const (
+	// RELATIVE_BASE adds the offset translation in the base frame and applies the
+	// rotation about the TCP, like URScript's pose_add
+	RELATIVE_BASE RelativeFrame = "base"
+	// RELATIVE_TOOL applies the offset in the tool frame, like URScript's pose_trans
+	RELATIVE_TOOL RelativeFrame = "tool"
+)
+
+// RelativeTarget computes the target of a relative move from the given TCP pose
+func RelativeTarget(from, offset URPose, frame RelativeFrame) (URPose, error) {
+	switch frame {
+	case RELATIVE_BASE, "":
+		return PoseAdd(from, offset), nil
+	case RELATIVE_TOOL:
+		return PoseTrans(from, offset), nil
+	default:
+		return URPose{}, fmt.Errorf("unknown relative frame: %q", frame)
+	}
+}
+
+// NewRelativeMoveCmd creates a move command that can be used as a step in a sequence
+func NewRelativeMoveCmd(offset URPose, frame RelativeFrame, options ...MoveOption) MoveCmd {
+	opts := MoveOptions{
+		Acceleration: DEFAULT_TOOL_ACCELERATION,
+		Velocity:     DEFAULT_TOOL_VELOCITY,
+	}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	return MoveCmd{
+		Type:         MOVE_L,
+		Offset:       &offset,
+		OffsetFrame:  frame,
+		From:         opts.From,
+		Acceleration: opts.Acceleration,
+		Velocity:     opts.Velocity,
+	}
+}
+
+// MoveRelative moves the TCP by offset from its current pose, in the base or tool frame.
+// The current pose is read on the robot unless WithFromPose is given.
+func (c *URController) MoveRelative(offset URPose, frame RelativeFrame, options ...MoveOption) error {
+	return c.MoveRelativeContext(c.Ctx, offset, frame, options...)
+}
+
+func (c *URController) MoveRelativeContext(ctx context.Context, offset URPose, frame RelativeFrame, options ...MoveOption) error {
+	cmd := NewRelativeMoveCmd(offset, frame, options...)
+	return c.sendMoves(ctx, cmd.String(), []MoveCmd{cmd})
+}
+
+// Target returns the target of a relative move when its start pose is known
+func (cmd MoveCmd) Target() (URPose, bool) {
+	if cmd.Offset == nil || cmd.From == nil {
+		return URPose{}, false
+	}
+	target, err := RelativeTarget(*cmd.From, *cmd.Offset, cmd.OffsetFrame)
+	if err != nil {
+		return URPose{}, false
+	}
+	return target, true
+}
+
+func (cmd MoveCmd) withRelativeDefaults() MoveCmd {
+	if cmd.Type == "" {
+		cmd.Type = MOVE_L
+	}
+	if cmd.Acceleration == 0 {
+		cmd.Acceleration = DEFAULT_TOOL_ACCELERATION
+	}
+	if cmd.Velocity == 0 {
+		cmd.Velocity = DEFAULT_TOOL_VELOCITY
+	}
+	return cmd
+}
+
+func (cmd *MoveCmd) relativeString() string {
+	*cmd = cmd.withRelativeDefaults()
+
+	from := "get_actual_tcp_pose()"
+	if cmd.From != nil {
+		from = cmd.From.String()
+	}
+
+	fn := "pose_add"
+	if cmd.OffsetFrame == RELATIVE_TOOL {
+		fn = "pose_trans"
+	}
+
+	return fmt.Sprintf("%s(%s(%s, %s), %s)", cmd.Type, fn, from, cmd.Offset.String(), cmd.moveArgs())
+}
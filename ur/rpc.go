@@ -0,0 +1,284 @@
+package ur
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReturnKind describes the value a URScript function returns
+type ReturnKind int
+
+const (
+	RETURN_NONE ReturnKind = iota
+	RETURN_NUMBER
+	RETURN_BOOL
+	RETURN_POSE   // p[x, y, z, rx, ry, rz]
+	RETURN_JOINTS // [q1, ..., q6]
+)
+
+const (
+	RPC_REGISTER_OWNER  = "rpc"
+	DEFAULT_RPC_TIMEOUT = 5 * time.Second
+)
+
+// size returns the number of registers needed for the return value
+func (k ReturnKind) size() int {
+	switch k {
+	case RETURN_NUMBER, RETURN_BOOL:
+		return 1
+	case RETURN_POSE, RETURN_JOINTS:
+		return 6
+	default:
+		return 0
+	}
+}
+
+// RPCResult is the decoded return value of a URScript function
+type RPCResult struct {
+	Kind   ReturnKind
+	Values []float64
+}
+
+func (r RPCResult) Float() float64 {
+	if len(r.Values) == 0 {
+		return 0
+	}
+	return r.Values[0]
+}
+
+func (r RPCResult) Bool() bool {
+	return r.Float() != 0
+}
+
+func (r RPCResult) Pose() URPose {
+	var p URPose
+	copy(p[:], r.Values)
+	return p
+}
+
+func (r RPCResult) Joints() URPosition {
+	var q URPosition
+	copy(q[:], r.Values)
+	return q
+}
+
+// RPC calls URScript functions on the robot and reads their return values over RTDE.
+// Each call runs as its own program, replacing whatever program is running.
+type RPC struct {
+	controller *URController
+	receiver   *URReceiver
+	timeout    time.Duration
+
+	sequence Register
+	values   [6]Register
+	recipe   uint8 // output recipe ID, 0 before Setup
+
+	mu  sync.Mutex
+	seq int32
+}
+
+// NewRPC allocates the result registers from the controller's register manager
+func NewRPC(controller *URController, receiver *URReceiver) (*RPC, error) {
+	regs := controller.Registers
+	r := &RPC{
+		controller: controller,
+		receiver:   receiver,
+		timeout:    DEFAULT_RPC_TIMEOUT,
+		seq:        int32(time.Now().Unix() % 100000),
+	}
+
+	var err error
+	r.sequence, err = regs.Allocate(RPC_REGISTER_OWNER, "sequence", REGISTER_INT, REGISTER_OUTPUT)
+	if err != nil {
+		return nil, err
+	}
+	for i := range r.values {
+		r.values[i], err = regs.Allocate(RPC_REGISTER_OWNER, fmt.Sprintf("value_%d", i), REGISTER_DOUBLE, REGISTER_OUTPUT)
+		if err != nil {
+			regs.Release(RPC_REGISTER_OWNER)
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// SetTimeout sets how long Call waits for the result when ctx has no deadline
+func (r *RPC) SetTimeout(d time.Duration) {
+	r.timeout = d
+}
+
+// OutputRecipe returns the RTDE output recipe with the result registers
+func (r *RPC) OutputRecipe() string {
+	vars := []string{r.sequence.Variable()}
+	for _, reg := range r.values {
+		vars = append(vars, reg.Variable())
+	}
+	return strings.Join(vars, ",")
+}
+
+// Setup configures the RTDE output recipe. Call it before StartDataExchange on the receiver.
+func (r *RPC) Setup() error {
+	return r.SetupContext(r.receiver.Ctx)
+}
+
+func (r *RPC) SetupContext(ctx context.Context) error {
+	resp, err := r.receiver.SendOutputSetupContext(ctx, r.OutputRecipe())
+	if err != nil {
+		return err
+	}
+	if strings.Contains(resp.Types, "NOT_FOUND") {
+		return fmt.Errorf("output setup rejected for %q: %s", r.OutputRecipe(), resp.Types)
+	}
+	r.recipe = resp.RecipeID
+	return nil
+}
+
+// Program returns the URScript program that calls fn and publishes its result
+func (r *RPC) Program(seq int32, fn string, returns ReturnKind, args ...any) (*URScript, error) {
+	if !scriptName.MatchString(fn) {
+		return nil, fmt.Errorf("invalid function name: %q", fn)
+	}
+
+	formatted := make([]string, len(args))
+	for i, arg := range args {
+		s, err := ScriptValue(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i, err)
+		}
+		formatted[i] = s
+	}
+	call := fmt.Sprintf("%s(%s)", fn, strings.Join(formatted, ", "))
+
+	program := NewURScript("rpc_call")
+	switch returns {
+	case RETURN_NONE:
+		program.Add(call)
+	case RETURN_NUMBER:
+		program.Addf("result = %s", call)
+		program.Addf("write_output_float_register(%d, result)", r.values[0].Index)
+	case RETURN_BOOL:
+		program.Addf("result = %s", call)
+		program.Add("if result:")
+		program.Addf("  write_output_float_register(%d, 1)", r.values[0].Index)
+		program.Add("else:")
+		program.Addf("  write_output_float_register(%d, 0)", r.values[0].Index)
+		program.Add("end")
+	case RETURN_POSE, RETURN_JOINTS:
+		program.Addf("result = %s", call)
+		for i, reg := range r.values {
+			program.Addf("write_output_float_register(%d, result[%d])", reg.Index, i)
+		}
+	default:
+		return nil, fmt.Errorf("unknown return kind: %d", returns)
+	}
+
+	// Publish the sequence last so Go never reads a half written result
+	program.Addf("write_output_integer_register(%d, %d)", r.sequence.Index, seq)
+	return program, nil
+}
+
+// Call runs fn(args...) on the robot and waits for its return value
+func (r *RPC) Call(ctx context.Context, fn string, returns ReturnKind, args ...any) (RPCResult, error) {
+	if r.recipe == 0 {
+		return RPCResult{}, fmt.Errorf("rpc is not set up")
+	}
+	if _, ok := ctx.Deadline(); !ok && r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
+	seq := r.seq
+
+	program, err := r.Program(seq, fn, returns, args...)
+	if err != nil {
+		return RPCResult{}, err
+	}
+	if err := r.controller.SendCommandContext(ctx, program.String()); err != nil {
+		return RPCResult{}, err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return RPCResult{}, fmt.Errorf("rpc %s: no result: %v", fn, ctx.Err())
+		default:
+		}
+
+		data, err := r.receiver.ListenRecipeContext(ctx, r.recipe)
+		if err != nil {
+			return RPCResult{}, err
+		}
+		if len(data) < 4+6*8 {
+			return RPCResult{}, fmt.Errorf("invalid data length for rpc result")
+		}
+
+		got, offset := UnpackField(data, 0, "INT32")
+		if got.(int32) != seq {
+			continue
+		}
+
+		values := make([]float64, returns.size())
+		for i := range values {
+			var v interface{}
+			v, offset = UnpackField(data, offset, TYPE_DOUBLE)
+			values[i] = v.(float64)
+		}
+		return RPCResult{Kind: returns, Values: values}, nil
+	}
+}
+
+// GetInverseKin calls get_inverse_kin on the robot
+func (r *RPC) GetInverseKin(ctx context.Context, pose URPose, seed URPosition) (URPosition, error) {
+	result, err := r.Call(ctx, "get_inverse_kin", RETURN_JOINTS, pose, seed)
+	if err != nil {
+		return URPosition{}, err
+	}
+	return result.Joints(), nil
+}
+
+// GetForwardKin calls get_forward_kin on the robot
+func (r *RPC) GetForwardKin(ctx context.Context, q URPosition) (URPose, error) {
+	result, err := r.Call(ctx, "get_forward_kin", RETURN_POSE, q)
+	if err != nil {
+		return URPose{}, err
+	}
+	return result.Pose(), nil
+}
+
+// ScriptValue formats a Go value as a URScript literal
+func ScriptValue(v any) (string, error) {
+	switch v := v.(type) {
+	case bool:
+		return scriptBool(v), nil
+	case int:
+		return fmt.Sprintf("%d", v), nil
+	case int32:
+		return fmt.Sprintf("%d", v), nil
+	case int64:
+		return fmt.Sprintf("%d", v), nil
+	case float64:
+		return fmt.Sprintf("%f", v), nil
+	case string:
+		if strings.ContainsAny(v, "\"\n") {
+			return "", fmt.Errorf("string %q cannot be passed to URScript", v)
+		}
+		return fmt.Sprintf("%q", v), nil
+	case URPose:
+		return v.String(), nil
+	case URPosition:
+		return floatArrayToString(v[:]), nil
+	case []float64:
+		return floatArrayToString(v), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", v)
+	}
+}
@@ -0,0 +1,52 @@
+package ur
+
+import (
+	"fmt"
+	"strings"
+)
+
+// URScript builds a URScript program from statements
+type URScript struct {
+	Name  string
+	lines []string
+}
+
+func NewURScript(name string) *URScript {
+	return &URScript{
+		Name: name,
+	}
+}
+
+// Add appends one or more raw statements. Multi-line statements are split into lines.
+func (s *URScript) Add(stmts ...string) *URScript {
+	for _, stmt := range stmts {
+		s.lines = append(s.lines, strings.Split(strings.TrimRight(stmt, "\n"), "\n")...)
+	}
+	return s
+}
+
+// Addf appends a formatted statement
+func (s *URScript) Addf(format string, args ...any) *URScript {
+	return s.Add(fmt.Sprintf(format, args...))
+}
+
+// Move appends a move command
+func (s *URScript) Move(cmd MoveCmd) *URScript {
+	return s.Add(cmd.String())
+}
+
+// Lines returns the statements of the program body
+func (s *URScript) Lines() []string {
+	return s.lines
+}
+
+// String returns the program wrapped in a function definition
+func (s *URScript) String() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("def %s():\n", s.Name))
+	for _, line := range s.lines {
+		b.WriteString("  " + line + "\n")
+	}
+	b.WriteString("end\n")
+	return b.String()
+}
@@ -134,9 +134,12 @@ func UnpackField(data []byte, offset int, dataType string) (interface{}, int) {
 			values[i] = int32(binary.BigEndian.Uint32(data[offset+i*4 : offset+(i+1)*4]))
 		}
 		return values, offset + size*4
-	case "INT32", "UINT8":
+	case "INT32":
 		value := int32(binary.BigEndian.Uint32(data[offset : offset+4]))
 		return value, offset + 4
+	case "UINT8":
+		value := data[offset]
+		return value, offset + 1
 	case "BOOL":
 		value := data[offset] != 0
 		return value, offset + 1
@@ -190,6 +193,8 @@ func (d *DataObject) Pack(names []string, types []string) ([]byte, error) {
 			}
 		case int32:
 			buf = binary.BigEndian.AppendUint32(buf, uint32(v))
+		case uint8:
+			buf = append(buf, v)
 		case bool:
 			if v {
 				buf = append(buf, 1)
@@ -0,0 +1,311 @@
+package ur
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SERVO_REGISTER_OWNER owns the input registers of the servo loop in the RegisterManager
+const SERVO_REGISTER_OWNER = "servo"
+
+// servoj defaults, see the URScript manual
+const (
+	DEFAULT_SERVO_LOOKAHEAD = 0.1
+	DEFAULT_SERVO_GAIN      = 300
+)
+
+type ServoOptions struct {
+	LookaheadTime float64       // [0.03, 0.2] s
+	Gain          float64       // [100, 2000]
+	Frequency     float64       // Hz, defaults to the model's RTDE frequency
+	Underrun      time.Duration // max time without a new target before stopping
+	Deceleration  float64       // rad/s^2 used to stop
+}
+
+type ServoOption func(*ServoOptions)
+
+func WithLookaheadTime(t float64) ServoOption {
+	return func(opts *ServoOptions) {
+		opts.LookaheadTime = t
+	}
+}
+
+func WithGain(gain float64) ServoOption {
+	return func(opts *ServoOptions) {
+		opts.Gain = gain
+	}
+}
+
+func WithFrequency(freq float64) ServoOption {
+	return func(opts *ServoOptions) {
+		opts.Frequency = freq
+	}
+}
+
+func WithUnderrunTimeout(d time.Duration) ServoOption {
+	return func(opts *ServoOptions) {
+		opts.Underrun = d
+	}
+}
+
+func WithDeceleration(a float64) ServoOption {
+	return func(opts *ServoOptions) {
+		opts.Deceleration = a
+	}
+}
+
+// ServoStreamer streams joint targets to a servoj loop running on the robot.
+// Targets are written to RTDE input registers every cycle; the robot stops when
+// the stream underruns, the context is cancelled or Stop is called.
+type ServoStreamer struct {
+	controller *URController
+	receiver   *URReceiver
+	opts       ServoOptions
+
+	mu        sync.Mutex
+	target    URPosition
+	commanded URPosition // target sent in the last cycle
+	updatedAt time.Time
+	counter   int32
+
+	control  Register    // int, 1 while streaming
+	watchdog Register    // int, incremented every cycle
+	targets  [6]Register // doubles holding the target joints
+
+	recipe *DataConfig
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// NewServoStreamer creates a streamer that uploads the servo program with the
+// controller and writes targets through the receiver's RTDE connection
+func NewServoStreamer(controller *URController, receiver *URReceiver, options ...ServoOption) *ServoStreamer {
+	opts := ServoOptions{
+		LookaheadTime: DEFAULT_SERVO_LOOKAHEAD,
+		Gain:          DEFAULT_SERVO_GAIN,
+		Frequency:     receiver.frequency(),
+		Underrun:      100 * time.Millisecond,
+		Deceleration:  DEFAULT_STOP_DECELERATION,
+	}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	return &ServoStreamer{
+		controller: controller,
+		receiver:   receiver,
+		opts:       opts,
+	}
+}
+
+// inputs returns the RTDE input recipe of the servo loop
+func (s *ServoStreamer) inputs() string {
+	vars := []string{s.control.Variable(), s.watchdog.Variable()}
+	for _, reg := range s.targets {
+		vars = append(vars, reg.Variable())
+	}
+	return strings.Join(vars, ",")
+}
+
+// Program returns the URScript servo loop reading the registers allocated by Start
+func (s *ServoStreamer) Program() *URScript {
+	period := 1 / s.opts.Frequency
+
+	targets := make([]string, 6)
+	for i, reg := range s.targets {
+		targets[i] = reg.ReadScript()
+	}
+
+	// The watchdog stops the program when the counter register stops changing
+	minFreq := 1 / s.opts.Underrun.Seconds()
+
+	return NewURScript("servo_stream").
+		Addf(`rtde_set_watchdog("%s", %f, "stop")`, s.watchdog.Variable(), minFreq).
+		Addf("while %s == 1:", s.control.ReadScript()).
+		Addf("  servoj([%s], 0, 0, %f, %f, %f)", strings.Join(targets, ", "), period, s.opts.LookaheadTime, s.opts.Gain).
+		Add("end").
+		StopJ(s.opts.Deceleration)
+}
+
+// Start uploads the servo program and starts streaming, holding the start position until the first Write.
+// The receiver must be connected and must not have started data exchange yet.
+func (s *ServoStreamer) Start(ctx context.Context, start URPosition) (err error) {
+	if err := ValidateMoveCmds([]MoveCmd{{PosA: start}}, s.controller.Model()); err != nil {
+		return err
+	}
+
+	if err := s.allocateRegisters(); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			s.controller.Registers.Release(SERVO_REGISTER_OWNER)
+		}
+	}()
+
+	recipe, err := s.receiver.SendInputSetupContext(ctx, s.inputs())
+	if err != nil {
+		return err
+	}
+	s.recipe = recipe
+
+	if err := s.receiver.StartDataExchangeContext(ctx); err != nil {
+		return err
+	}
+
+	s.target = start
+	s.commanded = start
+	s.updatedAt = time.Now()
+	if err := s.send(1); err != nil {
+		return err
+	}
+
+	if err := s.controller.SendCommandContext(ctx, s.Program().String()); err != nil {
+		return err
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.run(ctx)
+
+	slog.Info("Servo streaming started", "frequency", s.opts.Frequency)
+	return nil
+}
+
+// Write sets the next joint target. It must be called at least once per underrun timeout.
+// A target further away than the joint speed limits allow in one cycle is approached at
+// those limits over the following cycles.
+func (s *ServoStreamer) Write(q URPosition) error {
+	if err := ValidateMoveCmds([]MoveCmd{{PosA: q}}, s.controller.Model()); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.target = q
+	s.updatedAt = time.Now()
+	return nil
+}
+
+// allocateRegisters claims free input registers outside the fieldbus range for the
+// servo loop, so other components cannot use them
+func (s *ServoStreamer) allocateRegisters() error {
+	regs := s.controller.Registers
+
+	var err error
+	if s.control, err = regs.Allocate(SERVO_REGISTER_OWNER, "servo_control", REGISTER_INT, REGISTER_INPUT); err != nil {
+		regs.Release(SERVO_REGISTER_OWNER)
+		return err
+	}
+	if s.watchdog, err = regs.Allocate(SERVO_REGISTER_OWNER, "servo_watchdog", REGISTER_INT, REGISTER_INPUT); err != nil {
+		regs.Release(SERVO_REGISTER_OWNER)
+		return err
+	}
+	for i := range s.targets {
+		if s.targets[i], err = regs.Allocate(SERVO_REGISTER_OWNER, fmt.Sprintf("servo_target_%d", i), REGISTER_DOUBLE, REGISTER_INPUT); err != nil {
+			regs.Release(SERVO_REGISTER_OWNER)
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop ends streaming and waits for the loop to exit. It returns the reason streaming stopped, if any.
+func (s *ServoStreamer) Stop() error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	<-s.done
+	return s.err
+}
+
+// Done is closed when streaming has stopped
+func (s *ServoStreamer) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *ServoStreamer) run(ctx context.Context) {
+	defer close(s.done)
+	defer s.controller.Registers.Release(SERVO_REGISTER_OWNER)
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / s.opts.Frequency))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.halt(nil)
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		stale := time.Since(s.updatedAt) > s.opts.Underrun
+		s.mu.Unlock()
+
+		if stale {
+			s.halt(fmt.Errorf("servo stream underrun: no target for %s", s.opts.Underrun))
+			return
+		}
+
+		if err := s.send(1); err != nil {
+			s.halt(err)
+			return
+		}
+	}
+}
+
+// halt ends the servo loop on the robot, falling back to stopj when RTDE fails
+func (s *ServoStreamer) halt(reason error) {
+	s.err = reason
+	if reason != nil {
+		slog.Error("Stopping servo stream", "error", reason)
+	}
+
+	if err := s.send(0); err != nil {
+		slog.Error("Failed to stop servo loop over RTDE, sending stopj", "error", err)
+		if err := s.controller.StopJ(s.opts.Deceleration); err != nil {
+			slog.Error("Failed to send stopj", "error", err)
+		}
+	}
+}
+
+func (s *ServoStreamer) send(control int32) error {
+	s.mu.Lock()
+	s.counter++
+	s.commanded = s.limitStep(s.commanded, s.target)
+	fields := map[string]interface{}{
+		s.control.Variable():  control,
+		s.watchdog.Variable(): s.counter,
+	}
+	for i, q := range s.commanded {
+		fields[s.targets[i].Variable()] = q
+	}
+	s.mu.Unlock()
+
+	return s.receiver.SendInputs(s.recipe, &DataObject{Fields: fields})
+}
+
+// limitStep moves from towards to by at most the model's joint speeds over one cycle
+func (s *ServoStreamer) limitStep(from, to URPosition) URPosition {
+	model := s.controller.Model()
+	if model == nil {
+		return to
+	}
+
+	period := 1 / s.opts.Frequency
+	next := to
+	for j := range next {
+		step := model.MaxJointSpeeds[j] * period
+		next[j] = from[j] + math.Max(-step, math.Min(step, to[j]-from[j]))
+	}
+	return next
+}
@@ -0,0 +1,583 @@
+package ur
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SocketMessageKind is the first word of a line sent by the robot
+type SocketMessageKind string
+
+const (
+	SOCKET_HELLO   SocketMessageKind = "hello"   // hello <name>
+	SOCKET_BYE     SocketMessageKind = "bye"     // bye <name>
+	SOCKET_STRING  SocketMessageKind = "str"     // str <id> <text>
+	SOCKET_FLOATS  SocketMessageKind = "floats"  // floats <id> [v1, v2, ...]
+	SOCKET_REQUEST SocketMessageKind = "request" // request <id> <command>
+	SOCKET_ACK     SocketMessageKind = "ack"     // ack <id> <status>
+)
+
+// Tuples sent to the robot are (kind, id, code, v1, ..., v6) so URScript can read them
+// with socket_read_ascii_float
+const (
+	SOCKET_TUPLE_COMMAND = 1
+	SOCKET_TUPLE_REPLY   = 2
+	SOCKET_TUPLE_VALUES  = 6
+)
+
+// Status codes of replies and acknowledgements
+const (
+	SOCKET_STATUS_OK      = 0
+	SOCKET_STATUS_ERROR   = 1
+	SOCKET_STATUS_TIMEOUT = -1 // set by the URScript helpers when no reply arrives
+)
+
+const (
+	DEFAULT_SOCKET_PORT          = 50000
+	DEFAULT_SOCKET_HELLO_TIMEOUT = 5 * time.Second
+	DEFAULT_SOCKET_BUFFER        = 64
+)
+
+// SocketMessage is a message received from a robot program
+type SocketMessage struct {
+	Conn   string
+	Kind   SocketMessageKind
+	ID     int
+	Text   string    // string payload, request command or hello name
+	Values []float64 // float array payload
+	Status int       // acknowledgement status
+	Time   time.Time
+}
+
+// SocketRequestHandler answers a request from the robot with up to six values.
+// Returning an error replies with SOCKET_STATUS_ERROR.
+type SocketRequestHandler func(ctx context.Context, req SocketMessage) ([]float64, error)
+
+type SocketServerOptions struct {
+	Handler      SocketRequestHandler
+	HelloTimeout time.Duration // time a new connection has to introduce itself
+	Buffer       int           // size of the Messages channel
+}
+
+type SocketServerOption func(*SocketServerOptions)
+
+func WithRequestHandler(fn SocketRequestHandler) SocketServerOption {
+	return func(opts *SocketServerOptions) {
+		opts.Handler = fn
+	}
+}
+
+func WithHelloTimeout(d time.Duration) SocketServerOption {
+	return func(opts *SocketServerOptions) {
+		opts.HelloTimeout = d
+	}
+}
+
+func WithMessageBuffer(size int) SocketServerOption {
+	return func(opts *SocketServerOptions) {
+		opts.Buffer = size
+	}
+}
+
+// SocketServer accepts connections opened by robot programs with socket_open.
+//
+// Each robot program introduces itself with a name, which identifies the connection.
+// Strings and float arrays sent by the robot are delivered on Messages, requests are
+// answered by the request handler and commands are sent with SocketConn.Send.
+type SocketServer struct {
+	addr string
+	opts SocketServerOptions
+
+	mu        sync.Mutex
+	listener  net.Listener
+	conns     map[string]*SocketConn
+	connected chan struct{} // closed and replaced whenever a connection is added
+	messages  chan SocketMessage
+}
+
+// NewSocketServer creates a server listening on addr, e.g. ":50000"
+func NewSocketServer(addr string, options ...SocketServerOption) *SocketServer {
+	opts := SocketServerOptions{
+		HelloTimeout: DEFAULT_SOCKET_HELLO_TIMEOUT,
+		Buffer:       DEFAULT_SOCKET_BUFFER,
+	}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	return &SocketServer{
+		addr:      addr,
+		opts:      opts,
+		conns:     make(map[string]*SocketConn),
+		connected: make(chan struct{}),
+		messages:  make(chan SocketMessage, opts.Buffer),
+	}
+}
+
+// Addr returns the address the server listens on, or the configured address before Listen
+func (s *SocketServer) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Messages returns the strings, float arrays and hello/bye messages sent by robot programs.
+// Messages are dropped when nobody reads the channel.
+func (s *SocketServer) Messages() <-chan SocketMessage {
+	return s.messages
+}
+
+// Listen accepts connections until ctx is cancelled. It closes all connections on return.
+func (s *SocketServer) Listen(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	slog.Info("Socket server listening", "addr", listener.Addr().String())
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	defer func() {
+		s.mu.Lock()
+		conns := make([]*SocketConn, 0, len(s.conns))
+		for _, c := range s.conns {
+			conns = append(conns, c)
+		}
+		s.mu.Unlock()
+
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		go s.serve(ctx, conn)
+	}
+}
+
+// Conn returns the connection of a robot program
+func (s *SocketServer) Conn(name string) (*SocketConn, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.conns[name]
+	return c, ok
+}
+
+// WaitForConn blocks until a robot program with the given name has connected
+func (s *SocketServer) WaitForConn(ctx context.Context, name string) (*SocketConn, error) {
+	for {
+		s.mu.Lock()
+		c, ok := s.conns[name]
+		connected := s.connected
+		s.mu.Unlock()
+
+		if ok {
+			return c, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for robot program %q to connect: %v", name, ctx.Err())
+		case <-connected:
+		}
+	}
+}
+
+// serve reads the hello line, registers the connection and reads messages until it closes
+func (s *SocketServer) serve(ctx context.Context, conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(s.opts.HelloTimeout))
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		slog.Warn("Robot program did not introduce itself", "remote", conn.RemoteAddr().String(), "error", err)
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	hello, err := parseSocketMessage(line)
+	if err != nil || hello.Kind != SOCKET_HELLO || !scriptName.MatchString(hello.Text) {
+		slog.Warn("Invalid hello from robot program", "remote", conn.RemoteAddr().String(), "line", strings.TrimSpace(line))
+		conn.Close()
+		return
+	}
+
+	c := &SocketConn{
+		Name:    hello.Text,
+		conn:    conn,
+		pending: make(map[int]chan SocketMessage),
+		closed:  make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	previous := s.conns[c.Name]
+	s.conns[c.Name] = c
+	close(s.connected)
+	s.connected = make(chan struct{})
+	s.mu.Unlock()
+
+	// A program that reconnects replaces its old connection
+	if previous != nil {
+		previous.Close()
+	}
+
+	slog.Info("Robot program connected", "name", c.Name, "remote", conn.RemoteAddr().String())
+	hello.Conn, hello.Time = c.Name, time.Now()
+	s.deliver(hello)
+
+	err = c.read(reader, func(msg SocketMessage) {
+		switch msg.Kind {
+		case SOCKET_REQUEST:
+			go s.reply(ctx, c, msg)
+		default:
+			s.deliver(msg)
+		}
+	})
+
+	s.mu.Lock()
+	if s.conns[c.Name] == c {
+		delete(s.conns, c.Name)
+	}
+	s.mu.Unlock()
+	c.Close()
+
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		slog.Warn("Robot program connection lost", "name", c.Name, "error", err)
+	} else {
+		slog.Info("Robot program disconnected", "name", c.Name)
+	}
+}
+
+// reply answers a request with the request handler
+func (s *SocketServer) reply(ctx context.Context, c *SocketConn, req SocketMessage) {
+	status, values := SOCKET_STATUS_ERROR, []float64(nil)
+	if s.opts.Handler == nil {
+		slog.Warn("No handler for robot request", "name", c.Name, "command", req.Text)
+	} else if result, err := s.opts.Handler(ctx, req); err != nil {
+		slog.Warn("Robot request failed", "name", c.Name, "command", req.Text, "error", err)
+	} else {
+		status, values = SOCKET_STATUS_OK, result
+	}
+
+	if err := c.write(SOCKET_TUPLE_REPLY, req.ID, status, values); err != nil {
+		slog.Warn("Failed to reply to robot request", "name", c.Name, "error", err)
+	}
+}
+
+func (s *SocketServer) deliver(msg SocketMessage) {
+	select {
+	case s.messages <- msg:
+	default:
+		slog.Warn("Dropping robot message, nobody is reading", "name", msg.Conn, "kind", msg.Kind)
+	}
+}
+
+// Script returns the URScript helpers for a robot program named name connecting to
+// host. The helpers are prefixed with the name, which is also the socket name:
+//
+//	ok = <name>_open()
+//	<name>_send_string(s)
+//	<name>_send_floats(values)
+//	values = <name>_request(command, timeout)  # status in <name>_reply_status
+//	code = <name>_receive(timeout)             # -1 on timeout, values in <name>_command_values
+//	<name>_ack(status)
+//	<name>_close()
+func (s *SocketServer) Script(name, host string) (string, error) {
+	if !scriptName.MatchString(name) {
+		return "", fmt.Errorf("invalid socket name: %q", name)
+	}
+
+	port := DEFAULT_SOCKET_PORT
+	if _, p, err := net.SplitHostPort(s.Addr()); err == nil {
+		if n, err := strconv.Atoi(p); err == nil && n > 0 {
+			port = n
+		}
+	}
+
+	r := strings.NewReplacer(
+		"NAME", name,
+		"HOST", host,
+		"PORT", strconv.Itoa(port),
+		"TUPLE", strconv.Itoa(3+SOCKET_TUPLE_VALUES),
+		"KIND_COMMAND", strconv.Itoa(SOCKET_TUPLE_COMMAND),
+		"KIND_REPLY", strconv.Itoa(SOCKET_TUPLE_REPLY),
+		"STATUS_TIMEOUT", strconv.Itoa(SOCKET_STATUS_TIMEOUT),
+	)
+	return r.Replace(socketScript), nil
+}
+
+const socketScript = `global NAME_seq = 0
+global NAME_reply_status = 0
+global NAME_command_id = 0
+global NAME_command_values = [0, 0, 0, 0, 0, 0]
+global NAME_pending = [0, 0, 0, 0, 0, 0, 0, 0, 0, 0]
+def NAME_open():
+  if not socket_open("HOST", PORT, "NAME"):
+    return False
+  end
+  socket_send_line("hello NAME", "NAME")
+  return True
+end
+def NAME_close():
+  socket_send_line("bye NAME", "NAME")
+  socket_close("NAME")
+end
+def NAME_send_string(s):
+  NAME_seq = NAME_seq + 1
+  socket_send_line(str_cat(str_cat("str ", NAME_seq), str_cat(" ", s)), "NAME")
+end
+def NAME_send_floats(values):
+  NAME_seq = NAME_seq + 1
+  socket_send_line(str_cat(str_cat("floats ", NAME_seq), str_cat(" ", values)), "NAME")
+end
+def NAME_request(command, timeout):
+  NAME_seq = NAME_seq + 1
+  id = NAME_seq
+  socket_send_line(str_cat(str_cat("request ", id), str_cat(" ", command)), "NAME")
+  while True:
+    msg = socket_read_ascii_float(TUPLE, "NAME", timeout)
+    if msg[0] == 0:
+      NAME_reply_status = STATUS_TIMEOUT
+      return [0, 0, 0, 0, 0, 0]
+    end
+    if msg[1] == KIND_COMMAND:
+      NAME_pending = msg
+    elif msg[1] == KIND_REPLY and msg[2] == id:
+      NAME_reply_status = msg[3]
+      return [msg[4], msg[5], msg[6], msg[7], msg[8], msg[9]]
+    end
+  end
+end
+def NAME_receive(timeout):
+  while True:
+    if NAME_pending[0] > 0:
+      msg = NAME_pending
+      NAME_pending[0] = 0
+    else:
+      msg = socket_read_ascii_float(TUPLE, "NAME", timeout)
+      if msg[0] == 0:
+        return STATUS_TIMEOUT
+      end
+    end
+    if msg[1] == KIND_COMMAND:
+      NAME_command_id = msg[2]
+      NAME_command_values = [msg[4], msg[5], msg[6], msg[7], msg[8], msg[9]]
+      return msg[3]
+    end
+  end
+end
+def NAME_ack(status):
+  socket_send_line(str_cat(str_cat("ack ", NAME_command_id), str_cat(" ", status)), "NAME")
+end
+`
+
+// SocketConn is the connection of one robot program
+type SocketConn struct {
+	Name string
+	conn net.Conn
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan SocketMessage
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// Send sends a command with up to six values and waits for the robot program to
+// acknowledge it with <name>_ack. It returns the acknowledged status.
+func (c *SocketConn) Send(ctx context.Context, command int, values ...float64) (int, error) {
+	if len(values) > SOCKET_TUPLE_VALUES {
+		return 0, fmt.Errorf("too many values: %d > %d", len(values), SOCKET_TUPLE_VALUES)
+	}
+
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ack := make(chan SocketMessage, 1)
+	c.pending[id] = ack
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(SOCKET_TUPLE_COMMAND, id, command, values); err != nil {
+		return 0, err
+	}
+
+	select {
+	case msg := <-ack:
+		return msg.Status, nil
+	case <-c.closed:
+		return 0, fmt.Errorf("robot program %s disconnected before acknowledging command %d", c.Name, command)
+	case <-ctx.Done():
+		return 0, fmt.Errorf("robot program %s did not acknowledge command %d (id %d): %v", c.Name, command, id, ctx.Err())
+	}
+}
+
+// Done is closed when the connection closes
+func (c *SocketConn) Done() <-chan struct{} {
+	return c.closed
+}
+
+func (c *SocketConn) Close() error {
+	var err error
+	c.once.Do(func() {
+		close(c.closed)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// write sends a tuple padded to SOCKET_TUPLE_VALUES values
+func (c *SocketConn) write(kind, id, code int, values []float64) error {
+	fields := []string{strconv.Itoa(kind), strconv.Itoa(id), strconv.Itoa(code)}
+	for i := 0; i < SOCKET_TUPLE_VALUES; i++ {
+		v := 0.0
+		if i < len(values) {
+			v = values[i]
+		}
+		fields = append(fields, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_, err := c.conn.Write([]byte("(" + strings.Join(fields, ",") + ")\n"))
+	return err
+}
+
+// read dispatches messages until the connection closes. Acknowledgements are routed
+// to the waiting Send, everything else to fn.
+func (c *SocketConn) read(reader *bufio.Reader, fn func(SocketMessage)) error {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		msg, err := parseSocketMessage(line)
+		if err != nil {
+			slog.Warn("Invalid message from robot program", "name", c.Name, "error", err)
+			continue
+		}
+		msg.Conn, msg.Time = c.Name, time.Now()
+
+		switch msg.Kind {
+		case SOCKET_BYE:
+			fn(msg)
+			return nil
+		case SOCKET_ACK:
+			c.mu.Lock()
+			ack, ok := c.pending[msg.ID]
+			c.mu.Unlock()
+			if ok {
+				ack <- msg
+			}
+		default:
+			fn(msg)
+		}
+	}
+}
+
+// parseSocketMessage parses a line sent by the URScript helpers
+func parseSocketMessage(line string) (SocketMessage, error) {
+	line = strings.TrimRight(line, "\r\n")
+	parts := strings.SplitN(line, " ", 3)
+	msg := SocketMessage{Kind: SocketMessageKind(parts[0])}
+
+	switch msg.Kind {
+	case SOCKET_HELLO, SOCKET_BYE:
+		if len(parts) < 2 {
+			return msg, fmt.Errorf("missing name in %q", line)
+		}
+		msg.Text = parts[1]
+		return msg, nil
+	case SOCKET_STRING, SOCKET_FLOATS, SOCKET_REQUEST, SOCKET_ACK:
+	default:
+		return msg, fmt.Errorf("unknown message kind in %q", line)
+	}
+
+	if len(parts) < 2 {
+		return msg, fmt.Errorf("missing id in %q", line)
+	}
+	// URScript may format numbers as floats
+	id, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return msg, fmt.Errorf("invalid id in %q: %v", line, err)
+	}
+	msg.ID = int(id)
+
+	payload := ""
+	if len(parts) == 3 {
+		payload = parts[2]
+	}
+
+	switch msg.Kind {
+	case SOCKET_STRING, SOCKET_REQUEST:
+		msg.Text = payload
+	case SOCKET_FLOATS:
+		msg.Values, err = parseScriptList(payload)
+	case SOCKET_ACK:
+		var status float64
+		status, err = strconv.ParseFloat(strings.TrimSpace(payload), 64)
+		msg.Status = int(status)
+	}
+	if err != nil {
+		return msg, fmt.Errorf("invalid payload in %q: %v", line, err)
+	}
+	return msg, nil
+}
+
+// parseScriptList parses a URScript list or pose, e.g. [1.0, 2.0] or p[0.1, ...]
+func parseScriptList(s string) ([]float64, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "p")
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var values []float64
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
@@ -0,0 +1,161 @@
+package ur
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Decelerations used when stopping, in rad/s^2 and m/s^2
+const (
+	DEFAULT_STOP_DECELERATION      = 2.0
+	DEFAULT_TOOL_STOP_DECELERATION = 1.0
+)
+
+// SpeedJ returns a speedj statement. qd are joint speeds in rad/s, a is the leading
+// axis acceleration in rad/s^2 and t the time in seconds before returning. With a t of 0
+// the statement returns at once and the speed holds until the next statement, so the
+// program must keep running, e.g. in a loop.
+func SpeedJ(qd [6]float64, a, t float64) string {
+	if t > 0 {
+		return fmt.Sprintf("speedj(%s, %f, %f)", floatArrayToString(qd[:]), a, t)
+	}
+	return fmt.Sprintf("speedj(%s, %f)", floatArrayToString(qd[:]), a)
+}
+
+// SpeedL returns a speedl statement. xd is the tool speed in m/s and rad/s,
+// a the tool acceleration in m/s^2 and t the time in seconds before returning.
+// A t of 0 behaves as for SpeedJ.
+func SpeedL(xd [6]float64, a, t float64) string {
+	if t > 0 {
+		return fmt.Sprintf("speedl(%s, %f, %f)", floatArrayToString(xd[:]), a, t)
+	}
+	return fmt.Sprintf("speedl(%s, %f)", floatArrayToString(xd[:]), a)
+}
+
+// StopJ returns a stopj statement decelerating the joints at a rad/s^2
+func StopJ(a float64) string {
+	return fmt.Sprintf("stopj(%f)", a)
+}
+
+// StopL returns a stopl statement decelerating the tool at a m/s^2
+func StopL(a float64) string {
+	return fmt.Sprintf("stopl(%f)", a)
+}
+
+// SpeedJ appends a speedj statement
+func (s *URScript) SpeedJ(qd [6]float64, a, t float64) *URScript {
+	return s.Add(SpeedJ(qd, a, t))
+}
+
+// SpeedL appends a speedl statement
+func (s *URScript) SpeedL(xd [6]float64, a, t float64) *URScript {
+	return s.Add(SpeedL(xd, a, t))
+}
+
+// StopJ appends a stopj statement
+func (s *URScript) StopJ(a float64) *URScript {
+	return s.Add(StopJ(a))
+}
+
+// StopL appends a stopl statement
+func (s *URScript) StopL(a float64) *URScript {
+	return s.Add(StopL(a))
+}
+
+// SpeedJ sends a program that moves the joints at the given speeds for t seconds.
+// The program ends after t, so t must be positive.
+func (c *URController) SpeedJ(qd [6]float64, a, t float64) error {
+	return c.SpeedJContext(c.Ctx, qd, a, t)
+}
+
+func (c *URController) SpeedJContext(ctx context.Context, qd [6]float64, a, t float64) error {
+	if err := validateJointSpeeds(qd, a, c.Model()); err != nil {
+		return err
+	}
+	if t <= 0 {
+		return fmt.Errorf("time must be positive, got %f", t)
+	}
+	return c.SendCommandContext(ctx, SpeedJ(qd, a, t))
+}
+
+// SpeedL sends a program that moves the tool linearly at the given speed for t seconds.
+// The program ends after t, so t must be positive.
+func (c *URController) SpeedL(xd [6]float64, a, t float64) error {
+	return c.SpeedLContext(c.Ctx, xd, a, t)
+}
+
+func (c *URController) SpeedLContext(ctx context.Context, xd [6]float64, a, t float64) error {
+	if a <= 0 {
+		return fmt.Errorf("acceleration must be positive, got %f", a)
+	}
+	if t <= 0 {
+		return fmt.Errorf("time must be positive, got %f", t)
+	}
+	return c.SendCommandContext(ctx, SpeedL(xd, a, t))
+}
+
+// StopJ decelerates the joints to a stop. An a of 0 uses DEFAULT_STOP_DECELERATION.
+func (c *URController) StopJ(a float64) error {
+	return c.StopJContext(c.Ctx, a)
+}
+
+func (c *URController) StopJContext(ctx context.Context, a float64) error {
+	if a == 0 {
+		a = DEFAULT_STOP_DECELERATION
+	}
+	if a < 0 {
+		return fmt.Errorf("deceleration must be positive, got %f", a)
+	}
+	return c.SendCommandContext(ctx, StopJ(a))
+}
+
+// StopL decelerates the tool to a stop. An a of 0 uses DEFAULT_TOOL_STOP_DECELERATION.
+func (c *URController) StopL(a float64) error {
+	return c.StopLContext(c.Ctx, a)
+}
+
+func (c *URController) StopLContext(ctx context.Context, a float64) error {
+	if a == 0 {
+		a = DEFAULT_TOOL_STOP_DECELERATION
+	}
+	if a < 0 {
+		return fmt.Errorf("deceleration must be positive, got %f", a)
+	}
+	return c.SendCommandContext(ctx, StopL(a))
+}
+
+// Halt cancels the running program, e.g. one started by MoveJSequence. Sending a new
+// program replaces the running one, so this stops the arm and then halts.
+func (c *URController) Halt() error {
+	return c.HaltContext(c.Ctx)
+}
+
+func (c *URController) HaltContext(ctx context.Context) error {
+	a := DEFAULT_STOP_DECELERATION
+	if model := c.Model(); model != nil {
+		a = model.MaxAcceleration()
+	}
+
+	program := NewURScript("halt_program").
+		StopJ(a).
+		Add("halt")
+
+	return c.SendCommandContext(ctx, program.String())
+}
+
+func validateJointSpeeds(qd [6]float64, a float64, model *URModel) error {
+	if a <= 0 {
+		return fmt.Errorf("acceleration must be positive, got %f", a)
+	}
+
+	for j, v := range qd {
+		if math.IsNaN(v) {
+			return fmt.Errorf("joint %d speed is NaN", j+1)
+		}
+		if model != nil && math.Abs(v) > model.MaxJointSpeeds[j] {
+			return fmt.Errorf("joint %d speed %f rad/s exceeds %s limit of %f rad/s", j+1, v, model.Name, model.MaxJointSpeeds[j])
+		}
+	}
+	return nil
+}
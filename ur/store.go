@@ -0,0 +1,314 @@
+package ur
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PositionKind tells whether a stored position is a joint or a pose target
+type PositionKind string
+
+const (
+	POSITION_JOINTS PositionKind = "joints"
+	POSITION_POSE   PositionKind = "pose"
+)
+
+// AngleUnit is the unit of the angles in a stored position. Pose translations are always meters.
+type AngleUnit string
+
+const (
+	UNIT_RAD AngleUnit = "rad"
+	UNIT_DEG AngleUnit = "deg"
+)
+
+// StoredPosition is a named joint or pose target.
+// Joint targets hold six joint angles; pose targets hold x, y, z and a rotation
+// vector, expressed in Frame and using Tool.
+type StoredPosition struct {
+	Name   string       `json:"name" yaml:"name"`
+	Kind   PositionKind `json:"kind" yaml:"kind"`
+	Frame  string       `json:"frame,omitempty" yaml:"frame,omitempty"`
+	Tool   string       `json:"tool,omitempty" yaml:"tool,omitempty"`
+	Units  AngleUnit    `json:"units" yaml:"units"`
+	Values [6]float64   `json:"values" yaml:"values,flow"`
+
+	// Seed picks the inverse kinematics solution of a pose target, in radians
+	Seed *URPosition `json:"seed,omitempty" yaml:"seed,omitempty,flow"`
+	// Pose is the actual TCP pose recorded when a joint target was captured, in radians
+	Pose *URPose `json:"pose,omitempty" yaml:"pose,omitempty,flow"`
+}
+
+// Validate checks the kind, units and values of the position
+func (p StoredPosition) Validate() error {
+	var problems []string
+
+	if p.Name == "" {
+		problems = append(problems, "name is empty")
+	}
+
+	switch p.Kind {
+	case POSITION_JOINTS, POSITION_POSE:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown kind %q", p.Kind))
+	}
+
+	switch p.Units {
+	case UNIT_RAD, UNIT_DEG:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown units %q", p.Units))
+	}
+
+	for i, v := range p.Values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			problems = append(problems, fmt.Sprintf("value %d is %f", i, v))
+		}
+	}
+
+	// Catch degrees stored as radians
+	if p.Units == UNIT_RAD {
+		first := 0
+		if p.Kind == POSITION_POSE {
+			first = 3
+		}
+		for i := first; i < 6; i++ {
+			if math.Abs(p.Values[i]) > 2*math.Pi {
+				problems = append(problems, fmt.Sprintf("value %d of %.2f exceeds a full turn, units should be %q?", i, p.Values[i], UNIT_DEG))
+			}
+		}
+	}
+
+	if p.Kind == POSITION_JOINTS && (p.Frame != "" || p.Tool != "") {
+		problems = append(problems, "joint targets cannot have a frame or tool")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid position %q: %s", p.Name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// radians returns the values with angles converted to radians
+func (p StoredPosition) radians() [6]float64 {
+	values := p.Values
+	if p.Units != UNIT_DEG {
+		return values
+	}
+
+	first := 0
+	if p.Kind == POSITION_POSE {
+		first = 3
+	}
+	for i := first; i < 6; i++ {
+		values[i] = DegToRad(values[i])
+	}
+	return values
+}
+
+// Waypoint returns the pose target as a waypoint
+func (p StoredPosition) Waypoint() (Waypoint, error) {
+	if p.Kind != POSITION_POSE {
+		return Waypoint{}, fmt.Errorf("position %q is not a pose target", p.Name)
+	}
+	return Waypoint{
+		Frame: p.Frame,
+		Tool:  p.Tool,
+		Pose:  URPose(p.radians()),
+	}, nil
+}
+
+// PositionStore holds named positions and persists them as JSON or YAML
+type PositionStore struct {
+	mu        sync.RWMutex
+	positions map[string]StoredPosition
+}
+
+type positionFile struct {
+	Positions []StoredPosition `json:"positions" yaml:"positions"`
+}
+
+func NewPositionStore() *PositionStore {
+	return &PositionStore{
+		positions: make(map[string]StoredPosition),
+	}
+}
+
+// Set validates and adds or replaces a position
+func (s *PositionStore) Set(pos StoredPosition) error {
+	if err := pos.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.positions[pos.Name] = pos
+	return nil
+}
+
+// Get returns the position with the given name
+func (s *PositionStore) Get(name string) (StoredPosition, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pos, ok := s.positions[name]
+	return pos, ok
+}
+
+// Delete removes a position
+func (s *PositionStore) Delete(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.positions, name)
+}
+
+// Names returns the names of all positions, sorted
+func (s *PositionStore) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.positions))
+	for name := range s.positions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Joints resolves a position to joint angles in radians. Pose targets are converted
+// with the frames and the model's inverse kinematics.
+func (s *PositionStore) Joints(name string, frames *Frames, model *URModel) (URPosition, error) {
+	pos, ok := s.Get(name)
+	if !ok {
+		return URPosition{}, fmt.Errorf("unknown position: %q", name)
+	}
+
+	if pos.Kind == POSITION_JOINTS {
+		return URPosition(pos.radians()), nil
+	}
+
+	wp, err := pos.Waypoint()
+	if err != nil {
+		return URPosition{}, err
+	}
+
+	seed := DefaultIKSeed
+	if pos.Seed != nil {
+		seed = *pos.Seed
+	}
+	return frames.ToJoints(wp, model, seed)
+}
+
+// Pose resolves a pose target to the TCP pose in the base frame
+func (s *PositionStore) Pose(name string, frames *Frames) (URPose, error) {
+	pos, ok := s.Get(name)
+	if !ok {
+		return URPose{}, fmt.Errorf("unknown position: %q", name)
+	}
+
+	wp, err := pos.Waypoint()
+	if err != nil {
+		return URPose{}, err
+	}
+	return frames.ToBase(wp)
+}
+
+// Save writes the store to a file. Files ending in .yaml or .yml are written as YAML, others as JSON.
+func (s *PositionStore) Save(path string) error {
+	file := positionFile{}
+	for _, name := range s.Names() {
+		pos, _ := s.Get(name)
+		file.Positions = append(file.Positions, pos)
+	}
+
+	var data []byte
+	var err error
+	if isYAML(path) {
+		data, err = yaml.Marshal(file)
+	} else {
+		data, err = json.MarshalIndent(file, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	// Write next to the target and rename so a crash never leaves a truncated file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadPositionStore reads and validates a store from a JSON or YAML file
+func LoadPositionStore(path string) (*PositionStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file positionFile
+	if isYAML(path) {
+		err = yaml.Unmarshal(data, &file)
+	} else {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	var problems []string
+	store := NewPositionStore()
+	seen := make(map[string]bool)
+	for _, pos := range file.Positions {
+		if seen[pos.Name] {
+			problems = append(problems, fmt.Sprintf("duplicate position %q", pos.Name))
+			continue
+		}
+		seen[pos.Name] = true
+
+		if err := store.Set(pos); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid position file %s: %s", path, strings.Join(problems, "; "))
+	}
+	return store, nil
+}
+
+// JointsFor resolves a named position from the controller's store
+func (c *URController) JointsFor(name string) (URPosition, error) {
+	return c.Store.Joints(name, c.Frames, c.Model())
+}
+
+// MoveCmdTo returns a move command to a named position from the controller's store
+func (c *URController) MoveCmdTo(name string, options ...MoveOption) (MoveCmd, error) {
+	joints, err := c.JointsFor(name)
+	if err != nil {
+		return MoveCmd{}, err
+	}
+
+	opts := MoveOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	return MoveCmd{
+		PosA:         joints,
+		Type:         MOVE_J,
+		Acceleration: opts.Acceleration,
+		Velocity:     opts.Velocity,
+	}, nil
+}
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
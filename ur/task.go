@@ -0,0 +1,322 @@
+package ur
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var scriptName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Task is a declarative job, e.g. a pick and place cycle, that compiles to a URScript program
+type Task struct {
+	Name         string                `json:"name" yaml:"name"`
+	Acceleration float64               `json:"acceleration,omitempty" yaml:"acceleration,omitempty"`
+	Velocity     float64               `json:"velocity,omitempty" yaml:"velocity,omitempty"`
+	Blend        float64               `json:"blend,omitempty" yaml:"blend,omitempty"`
+	Steps        []TaskStep            `json:"steps" yaml:"steps"`
+	Subroutines  map[string][]TaskStep `json:"subroutines,omitempty" yaml:"subroutines,omitempty"`
+}
+
+// TaskStep is a single step of a task. Exactly one action must be set.
+type TaskStep struct {
+	// Move to a named position from the position store
+	Move         string  `json:"move,omitempty" yaml:"move,omitempty"`
+	Type         string  `json:"type,omitempty" yaml:"type,omitempty"`
+	Acceleration float64 `json:"acceleration,omitempty" yaml:"acceleration,omitempty"`
+	Velocity     float64 `json:"velocity,omitempty" yaml:"velocity,omitempty"`
+	Blend        float64 `json:"blend,omitempty" yaml:"blend,omitempty"`
+
+	// Repeat Steps Loop times
+	Loop  int        `json:"loop,omitempty" yaml:"loop,omitempty"`
+	Steps []TaskStep `json:"steps,omitempty" yaml:"steps,omitempty"`
+
+	SetOutput *IOStep `json:"set_output,omitempty" yaml:"set_output,omitempty"`
+	WaitInput *IOStep `json:"wait_input,omitempty" yaml:"wait_input,omitempty"`
+
+	// Call a subroutine of the task
+	Call string `json:"call,omitempty" yaml:"call,omitempty"`
+
+	// Sleep in seconds
+	Sleep float64 `json:"sleep,omitempty" yaml:"sleep,omitempty"`
+}
+
+// IOStep sets or waits for a standard digital pin
+type IOStep struct {
+	Pin   int  `json:"pin" yaml:"pin"`
+	Value bool `json:"value" yaml:"value"`
+}
+
+// LoadTask reads a task from a JSON or YAML file
+func LoadTask(path string) (*Task, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	task := &Task{}
+	if isYAML(path) {
+		err = yaml.Unmarshal(data, task)
+	} else {
+		err = json.Unmarshal(data, task)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return task, nil
+}
+
+// taskCompiler holds the state of a single compilation
+type taskCompiler struct {
+	task   *Task
+	store  *PositionStore
+	frames *Frames
+	model  *URModel
+
+	moves    []MoveCmd
+	problems []string
+	loops    int
+}
+
+// Compile validates the task and compiles it to a URScript program. Positions are
+// resolved from the store, pose targets through the frames and the model's kinematics.
+// All problems, including move validation, are returned together.
+func (t *Task) Compile(store *PositionStore, frames *Frames, model *URModel) (*URScript, error) {
+	program, moves, problems := t.compile(store, frames, model)
+	if err := ValidateMoveCmds(moves, model); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid task %q: %s", t.Name, strings.Join(problems, "; "))
+	}
+	return program, nil
+}
+
+// compile builds the program and returns its moves and the problems other than move validation
+func (t *Task) compile(store *PositionStore, frames *Frames, model *URModel) (*URScript, []MoveCmd, []string) {
+	c := &taskCompiler{
+		task:   t,
+		store:  store,
+		frames: frames,
+		model:  model,
+	}
+
+	if !scriptName.MatchString(t.Name) {
+		c.fail("task", "invalid name %q", t.Name)
+	}
+	if len(t.Steps) == 0 {
+		c.fail("task", "no steps")
+	}
+	c.checkRecursion()
+
+	program := NewURScript(t.Name)
+
+	// Subroutines are defined first so the main steps can call them
+	names := make([]string, 0, len(t.Subroutines))
+	for name := range t.Subroutines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		where := "subroutines." + name
+		if !scriptName.MatchString(name) {
+			c.fail(where, "invalid name")
+		}
+		program.Addf("def %s():", name)
+		c.compileSteps(program, t.Subroutines[name], where, "  ")
+		program.Add("end")
+	}
+
+	c.compileSteps(program, t.Steps, "steps", "")
+	return program, c.moves, c.problems
+}
+
+// Validate checks the task without keeping the program
+func (t *Task) Validate(store *PositionStore, frames *Frames, model *URModel) error {
+	_, err := t.Compile(store, frames, model)
+	return err
+}
+
+func (c *taskCompiler) fail(where, format string, args ...any) {
+	c.problems = append(c.problems, where+": "+fmt.Sprintf(format, args...))
+}
+
+func (c *taskCompiler) compileSteps(program *URScript, steps []TaskStep, where, indent string) {
+	for i, step := range steps {
+		c.compileStep(program, step, fmt.Sprintf("%s[%d]", where, i), indent)
+	}
+}
+
+func (c *taskCompiler) compileStep(program *URScript, step TaskStep, where, indent string) {
+	actions := 0
+	for _, set := range []bool{step.Move != "", step.Loop != 0 || step.Steps != nil, step.SetOutput != nil, step.WaitInput != nil, step.Call != "", step.Sleep != 0} {
+		if set {
+			actions++
+		}
+	}
+	if actions != 1 {
+		c.fail(where, "expected exactly one action, got %d", actions)
+		return
+	}
+
+	switch {
+	case step.Move != "":
+		cmd, ok := c.moveCmd(step, where)
+		if ok {
+			program.Add(indent + cmd.String())
+		}
+
+	case step.Loop != 0 || step.Steps != nil:
+		if step.Loop <= 0 {
+			c.fail(where, "loop count must be positive, got %d", step.Loop)
+		}
+		if len(step.Steps) == 0 {
+			c.fail(where, "loop has no steps")
+		}
+
+		c.loops++
+		counter := fmt.Sprintf("loop_%d", c.loops)
+		program.Addf("%s%s = 0", indent, counter)
+		program.Addf("%swhile %s < %d:", indent, counter, step.Loop)
+		c.compileSteps(program, step.Steps, where+".steps", indent+"  ")
+		program.Addf("%s  %s = %s + 1", indent, counter, counter)
+		program.Add(indent + "end")
+
+	case step.SetOutput != nil:
+		if c.checkPin(step.SetOutput.Pin, where) {
+			program.Addf("%sset_standard_digital_out(%d, %s)", indent, step.SetOutput.Pin, scriptBool(step.SetOutput.Value))
+		}
+
+	case step.WaitInput != nil:
+		if c.checkPin(step.WaitInput.Pin, where) {
+			program.Addf("%swhile get_standard_digital_in(%d) != %s:", indent, step.WaitInput.Pin, scriptBool(step.WaitInput.Value))
+			program.Add(indent + "  sync()")
+			program.Add(indent + "end")
+		}
+
+	case step.Call != "":
+		if _, ok := c.task.Subroutines[step.Call]; !ok {
+			c.fail(where, "unknown subroutine %q", step.Call)
+			return
+		}
+		program.Addf("%s%s()", indent, step.Call)
+
+	case step.Sleep != 0:
+		if step.Sleep < 0 {
+			c.fail(where, "negative sleep %f", step.Sleep)
+			return
+		}
+		program.Addf("%ssleep(%f)", indent, step.Sleep)
+	}
+}
+
+func (c *taskCompiler) moveCmd(step TaskStep, where string) (MoveCmd, bool) {
+	joints, err := c.store.Joints(step.Move, c.frames, c.model)
+	if err != nil {
+		c.fail(where, "%v", err)
+		return MoveCmd{}, false
+	}
+
+	cmd := MoveCmd{
+		PosA:         joints,
+		Type:         step.Type,
+		Acceleration: firstPositive(step.Acceleration, c.task.Acceleration),
+		Velocity:     firstPositive(step.Velocity, c.task.Velocity),
+		Blend:        firstPositive(step.Blend, c.task.Blend),
+	}
+	cmd = cmd.withDefaults(defaultMoveOptions(c.model))
+
+	c.moves = append(c.moves, cmd)
+	return cmd, true
+}
+
+// checkPin checks that pin is a standard digital pin of the control box
+func (c *taskCompiler) checkPin(pin int, where string) bool {
+	if _, err := (DigitalPin{Group: IO_STANDARD, Index: pin}).bit(); err != nil {
+		c.fail(where, "%v", err)
+		return false
+	}
+	return true
+}
+
+// checkRecursion reports subroutines that call themselves, directly or indirectly
+func (c *taskCompiler) checkRecursion() {
+	calls := make(map[string][]string)
+	var collect func(name string, steps []TaskStep)
+	collect = func(name string, steps []TaskStep) {
+		for _, step := range steps {
+			if step.Call != "" {
+				calls[name] = append(calls[name], step.Call)
+			}
+			collect(name, step.Steps)
+		}
+	}
+	for name, steps := range c.task.Subroutines {
+		collect(name, steps)
+	}
+
+	var visit func(name string, path []string) bool
+	visit = func(name string, path []string) bool {
+		for _, p := range path {
+			if p == name {
+				c.fail("subroutines."+name, "recursive call %s", strings.Join(append(path, name), " -> "))
+				return true
+			}
+		}
+		for _, callee := range calls[name] {
+			if visit(callee, append(path, name)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	names := make([]string, 0, len(c.task.Subroutines))
+	for name := range c.task.Subroutines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if visit(name, nil) {
+			return
+		}
+	}
+}
+
+// RunTask compiles a task against the controller's positions, frames and model and sends it.
+// Its moves are validated like those of MoveJSequence, and recorded in dry-run mode.
+func (c *URController) RunTask(task *Task) error {
+	return c.RunTaskContext(c.Ctx, task)
+}
+
+func (c *URController) RunTaskContext(ctx context.Context, task *Task) error {
+	program, moves, problems := task.compile(c.Store, c.Frames, c.Model())
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid task %q: %s", task.Name, strings.Join(problems, "; "))
+	}
+	return c.sendMoves(ctx, program.String(), moves)
+}
+
+func scriptBool(v bool) string {
+	if v {
+		return "True"
+	}
+	return "False"
+}
+
+func firstPositive(values ...float64) float64 {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,167 @@
+package ur
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// RTDE recipe used to capture waypoints
+const (
+	RECIPE_CAPTURE = "actual_q,actual_TCP_pose"
+)
+
+// Freedrive features, i.e. the frame the free axes refer to
+const (
+	FREEDRIVE_FEATURE_BASE = "base"
+	FREEDRIVE_FEATURE_TOOL = "tool"
+)
+
+// FreedriveConstraints restricts freedrive to some axes. Only supported on e-Series.
+type FreedriveConstraints struct {
+	FreeAxes [6]bool // x, y, z, rx, ry, rz of the feature
+	Feature  string  // FREEDRIVE_FEATURE_BASE or FREEDRIVE_FEATURE_TOOL
+}
+
+func (f FreedriveConstraints) String() string {
+	axes := make([]string, 6)
+	for i, free := range f.FreeAxes {
+		axes[i] = "0"
+		if free {
+			axes[i] = "1"
+		}
+	}
+	return fmt.Sprintf(`freedrive_mode(freeAxes=[%s], feature="%s")`, strings.Join(axes, ", "), f.Feature)
+}
+
+// StartFreedrive puts the arm in freedrive until EndFreedrive is called. Freedrive only lasts
+// while a program runs, so this uploads a program that idles in freedrive mode.
+// Pass nil constraints to free all axes.
+func (c *URController) StartFreedrive(constraints *FreedriveConstraints) error {
+	return c.StartFreedriveContext(c.Ctx, constraints)
+}
+
+func (c *URController) StartFreedriveContext(ctx context.Context, constraints *FreedriveConstraints) error {
+	stmt := "freedrive_mode()"
+	if constraints != nil {
+		if model := c.Model(); model != nil && !model.IsESeries() {
+			return fmt.Errorf("freedrive axis constraints are not supported on %s", model)
+		}
+		switch constraints.Feature {
+		case FREEDRIVE_FEATURE_BASE, FREEDRIVE_FEATURE_TOOL:
+		default:
+			return fmt.Errorf("unknown freedrive feature: %q", constraints.Feature)
+		}
+		stmt = constraints.String()
+	}
+
+	program := NewURScript("freedrive").
+		Add(stmt).
+		Add("while True:", "  sleep(1)", "end")
+
+	return c.SendCommandContext(ctx, program.String())
+}
+
+// EndFreedrive leaves freedrive by replacing the freedrive program
+func (c *URController) EndFreedrive() error {
+	return c.EndFreedriveContext(c.Ctx)
+}
+
+func (c *URController) EndFreedriveContext(ctx context.Context) error {
+	program := NewURScript("end_freedrive").
+		Add("end_freedrive_mode()")
+
+	return c.SendCommandContext(ctx, program.String())
+}
+
+// TeachSession captures waypoints while the arm is moved by hand
+type TeachSession struct {
+	Controller *URController
+	Receiver   *URReceiver
+	Store      *PositionStore
+	recipe     uint8 // capture recipe ID, 0 before Start
+}
+
+func NewTeachSession(controller *URController, receiver *URReceiver, store *PositionStore) *TeachSession {
+	return &TeachSession{
+		Controller: controller,
+		Receiver:   receiver,
+		Store:      store,
+	}
+}
+
+// Start configures the receiver to stream the capture recipe and starts freedrive.
+// The receiver must be connected and must not have started data exchange yet.
+func (t *TeachSession) Start(constraints *FreedriveConstraints) error {
+	return t.StartContext(t.Receiver.Ctx, constraints)
+}
+
+func (t *TeachSession) StartContext(ctx context.Context, constraints *FreedriveConstraints) error {
+	resp, err := t.Receiver.SendOutputSetupContext(ctx, RECIPE_CAPTURE)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(resp.Types, "NOT_FOUND") {
+		return fmt.Errorf("output setup rejected for %q: %s", RECIPE_CAPTURE, resp.Types)
+	}
+	t.recipe = resp.RecipeID
+
+	if err := t.Receiver.StartDataExchangeContext(ctx); err != nil {
+		return err
+	}
+
+	return t.Controller.StartFreedriveContext(ctx, constraints)
+}
+
+// End leaves freedrive
+func (t *TeachSession) End() error {
+	return t.EndContext(t.Controller.Ctx)
+}
+
+func (t *TeachSession) EndContext(ctx context.Context) error {
+	return t.Controller.EndFreedriveContext(ctx)
+}
+
+// CaptureWaypoint reads the current joints and TCP pose and stores them under name
+func (t *TeachSession) CaptureWaypoint(name string) (StoredPosition, error) {
+	return t.CaptureWaypointContext(t.Receiver.Ctx, name)
+}
+
+// CaptureWaypointContext waits for the first sample that arrives after the call, so the
+// waypoint is where the arm is now and not a sample received while it was still moving
+func (t *TeachSession) CaptureWaypointContext(ctx context.Context, name string) (StoredPosition, error) {
+	if t.recipe == 0 {
+		return StoredPosition{}, fmt.Errorf("teach session is not started")
+	}
+
+	data, err := t.Receiver.ListenRecipeContext(ctx, t.recipe)
+	if err != nil {
+		return StoredPosition{}, err
+	}
+	if len(data) < 12*8 {
+		return StoredPosition{}, fmt.Errorf("invalid data length for %s", RECIPE_CAPTURE)
+	}
+
+	q, offset := UnpackField(data, 0, TYPE_VECTOR_6D)
+	p, _ := UnpackField(data, offset, TYPE_VECTOR_6D)
+
+	var joints URPosition
+	var pose URPose
+	copy(joints[:], q.([]float64))
+	copy(pose[:], p.([]float64))
+
+	pos := StoredPosition{
+		Name:   name,
+		Kind:   POSITION_JOINTS,
+		Units:  UNIT_RAD,
+		Values: joints,
+		Pose:   &pose,
+	}
+	if err := t.Store.Set(pos); err != nil {
+		return StoredPosition{}, err
+	}
+
+	slog.Info("Captured waypoint", "name", name, "joints", joints, "pose", pose)
+	return pos, nil
+}
@@ -0,0 +1,159 @@
+package ur
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// genericJointRange is used when the robot model is unknown
+var genericJointRange = JointRange{Min: -2 * math.Pi, Max: 2 * math.Pi}
+
+// Violation describes a single problem found in a move command
+type Violation struct {
+	Cmd      int    // index of the command in the program
+	Location string // e.g. "PosA", "ViaPos[1]", "Velocity"
+	Joint    int    // joint index, or -1 when not joint specific
+	Message  string
+}
+
+func (v Violation) String() string {
+	if v.Joint >= 0 {
+		return fmt.Sprintf("cmd[%d].%s joint %d: %s", v.Cmd, v.Location, v.Joint+1, v.Message)
+	}
+	return fmt.Sprintf("cmd[%d].%s: %s", v.Cmd, v.Location, v.Message)
+}
+
+// ValidationError collects all violations found in a program
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("%d validation error(s): %s", len(e.Violations), strings.Join(msgs, "; "))
+}
+
+// ValidateMoveCmds checks every target, via point and loop body of the commands
+// against the joint ranges and speed limits of the model. A nil model only checks
+// for out-of-turn values and unit mistakes. All violations are returned together.
+func ValidateMoveCmds(cmds []MoveCmd, model *URModel) error {
+	var violations []Violation
+	for i, cmd := range cmds {
+		violations = append(violations, validateMoveCmd(i, cmd, model)...)
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+func validateMoveCmd(index int, cmd MoveCmd, model *URModel) []Violation {
+	var violations []Violation
+
+	if cmd.Offset != nil {
+		violations = append(violations, validateRelative(index, cmd)...)
+	} else {
+		violations = append(violations, validatePosition(index, "PosA", cmd.PosA, model)...)
+		for i, via := range cmd.ViaPos {
+			violations = append(violations, validatePosition(index, fmt.Sprintf("ViaPos[%d]", i), via, model)...)
+		}
+		if cmd.PosB != nil {
+			violations = append(violations, validatePosition(index, "PosB", *cmd.PosB, model)...)
+		}
+	}
+
+	if cmd.Iterations < 0 {
+		violations = append(violations, Violation{index, "Iterations", -1, fmt.Sprintf("negative iteration count %d", cmd.Iterations)})
+	}
+	if cmd.Iterations > 0 && cmd.PosB == nil {
+		violations = append(violations, Violation{index, "Iterations", -1, "loop requires PosB"})
+	}
+
+	switch cmd.Type {
+	case "", MOVE_J, MOVE_L, MOVE_P:
+	default:
+		violations = append(violations, Violation{index, "Type", -1, fmt.Sprintf("unknown move type %q", cmd.Type)})
+	}
+
+	if cmd.Velocity < 0 {
+		violations = append(violations, Violation{index, "Velocity", -1, fmt.Sprintf("negative velocity %f", cmd.Velocity)})
+	}
+	if cmd.Blend < 0 {
+		violations = append(violations, Violation{index, "Blend", -1, fmt.Sprintf("negative blend radius %f", cmd.Blend)})
+	}
+	if cmd.Acceleration < 0 {
+		violations = append(violations, Violation{index, "Acceleration", -1, fmt.Sprintf("negative acceleration %f", cmd.Acceleration)})
+	}
+
+	// Speed ceilings are joint space limits and only apply to movej. The speed applies to
+	// the leading joint, which can be any joint, so the slowest joint's limit is the ceiling.
+	if model != nil && (cmd.Type == "" || cmd.Type == MOVE_J) {
+		maxSpeed := model.MaxVelocity()
+		if cmd.Velocity > maxSpeed {
+			violations = append(violations, Violation{index, "Velocity", -1, fmt.Sprintf("%f rad/s exceeds %s limit of %f rad/s", cmd.Velocity, model.Name, maxSpeed)})
+		}
+		maxAcc := model.MaxAcceleration()
+		if cmd.Acceleration > maxAcc {
+			violations = append(violations, Violation{index, "Acceleration", -1, fmt.Sprintf("%f rad/s^2 exceeds %s limit of %f rad/s^2", cmd.Acceleration, model.Name, maxAcc)})
+		}
+	}
+
+	return violations
+}
+
+func validateRelative(index int, cmd MoveCmd) []Violation {
+	var violations []Violation
+
+	switch cmd.OffsetFrame {
+	case "", RELATIVE_BASE, RELATIVE_TOOL:
+	default:
+		violations = append(violations, Violation{index, "OffsetFrame", -1, fmt.Sprintf("unknown relative frame %q", cmd.OffsetFrame)})
+	}
+
+	if cmd.ViaPos != nil || cmd.PosB != nil || cmd.Iterations != 0 {
+		violations = append(violations, Violation{index, "Offset", -1, "relative moves cannot have via points, PosB or iterations"})
+	}
+
+	rv := cmd.Offset.RotVec()
+	for i, r := range rv {
+		if math.Abs(r) > 2*math.Pi {
+			violations = append(violations, Violation{index, "Offset", -1, fmt.Sprintf("rotation component %d of %.2f exceeds a full turn, degrees passed where radians are expected?", i, r)})
+		}
+	}
+
+	return violations
+}
+
+func validatePosition(index int, location string, pos URPosition, model *URModel) []Violation {
+	var violations []Violation
+
+	for j, q := range pos {
+		if math.IsNaN(q) || math.IsInf(q, 0) {
+			violations = append(violations, Violation{index, location, j, fmt.Sprintf("invalid value %f", q)})
+			continue
+		}
+
+		limits := genericJointRange
+		if model != nil {
+			limits = model.JointRanges[j]
+		}
+
+		// Joints with a limited range cannot be commanded more than a full turn in
+		// radians, so larger values are almost always degrees. Endless joints can.
+		if !math.IsInf(limits.Max, 1) && math.Abs(q) > 2*math.Pi {
+			violations = append(violations, Violation{index, location, j, fmt.Sprintf("%.2f exceeds a full turn, degrees passed where radians are expected?", q)})
+			continue
+		}
+
+		if !limits.Contains(q) {
+			violations = append(violations, Violation{index, location, j, fmt.Sprintf("%.4f rad outside range [%.4f, %.4f]", q, limits.Min, limits.Max)})
+		}
+	}
+
+	return violations
+}
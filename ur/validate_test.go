@@ -0,0 +1,130 @@
+package ur
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestValidateMoveCmds(t *testing.T) {
+	ur3, err := LookupModel("UR3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ur10e, err := LookupModel("UR10e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	home := DefaultPositionMap.Home
+	offset := URPose{0, 0, 0.1, 0, 0, 0}
+
+	tests := []struct {
+		name  string
+		cmds  []MoveCmd
+		model *URModel
+		want  []Violation // only Cmd, Location and Joint are compared
+	}{
+		{
+			name:  "valid movej",
+			cmds:  []MoveCmd{{PosA: home, Type: MOVE_J, Velocity: 1, Acceleration: 1}},
+			model: ur10e,
+		},
+		{
+			name: "degrees without a model",
+			cmds: []MoveCmd{{PosA: URPosition{90, 0, 0, 0, 0, 0}}},
+			want: []Violation{{0, "PosA", 0, ""}},
+		},
+		{
+			name:  "degrees in a via point",
+			cmds:  []MoveCmd{{PosA: home, ViaPos: []URPosition{home, {0, 0, -120, 0, 0, 0}}}},
+			model: ur10e,
+			want:  []Violation{{0, "ViaPos[1]", 2, ""}},
+		},
+		{
+			name:  "endless joint past a full turn",
+			cmds:  []MoveCmd{{PosA: URPosition{0, -1.5, 1.5, -1.5, -1.5, 3 * math.Pi}}},
+			model: ur3,
+		},
+		{
+			name:  "limited joint past a full turn",
+			cmds:  []MoveCmd{{PosA: URPosition{0, -1.5, 1.5, -1.5, 3 * math.Pi, 0}}},
+			model: ur3,
+			want:  []Violation{{0, "PosA", 4, ""}},
+		},
+		{
+			name:  "outside a half turn range",
+			cmds:  []MoveCmd{{PosA: URPosition{0, -1.5, 4, -1.5, -1.5, 0}}},
+			model: ur10e,
+			want:  []Violation{{0, "PosA", 2, ""}},
+		},
+		{
+			name:  "NaN in PosB",
+			cmds:  []MoveCmd{{PosA: home, PosB: &URPosition{0, math.NaN(), 0, 0, 0, 0}, Iterations: 1}},
+			model: ur10e,
+			want:  []Violation{{0, "PosB", 1, ""}},
+		},
+		{
+			name:  "movej faster than the slowest joint",
+			cmds:  []MoveCmd{{PosA: home, Type: MOVE_J, Velocity: 3, Acceleration: 1}},
+			model: ur10e,
+			want:  []Violation{{0, "Velocity", -1, ""}},
+		},
+		{
+			name:  "movel speed is not a joint speed",
+			cmds:  []MoveCmd{{PosA: home, Type: MOVE_L, Velocity: 3, Acceleration: 1}},
+			model: ur10e,
+		},
+		{
+			name:  "movej acceleration limit",
+			cmds:  []MoveCmd{{PosA: home, Acceleration: 20}},
+			model: ur10e,
+			want:  []Violation{{0, "Acceleration", -1, ""}},
+		},
+		{
+			name: "loop without PosB and negative values",
+			cmds: []MoveCmd{{PosA: home, Iterations: 2, Velocity: -1, Blend: -0.1}},
+			want: []Violation{{0, "Iterations", -1, ""}, {0, "Velocity", -1, ""}, {0, "Blend", -1, ""}},
+		},
+		{
+			name: "unknown move type",
+			cmds: []MoveCmd{{PosA: home}, {PosA: home, Type: "movec"}},
+			want: []Violation{{1, "Type", -1, ""}},
+		},
+		{
+			name: "relative move",
+			cmds: []MoveCmd{NewRelativeMoveCmd(offset, RELATIVE_TOOL)},
+		},
+		{
+			name: "relative move in degrees with a via point",
+			cmds: []MoveCmd{{Offset: &URPose{0, 0, 0, 0, 0, 90}, OffsetFrame: RELATIVE_BASE, ViaPos: []URPosition{home}}},
+			want: []Violation{{0, "Offset", -1, ""}, {0, "Offset", -1, ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMoveCmds(tt.cmds, tt.model)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateMoveCmds() = %v, want nil", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("ValidateMoveCmds() = %v, want a ValidationError", err)
+			}
+			if len(verr.Violations) != len(tt.want) {
+				t.Fatalf("ValidateMoveCmds() = %v, want %d violation(s)", err, len(tt.want))
+			}
+			for i, v := range verr.Violations {
+				w := tt.want[i]
+				if v.Cmd != w.Cmd || v.Location != w.Location || v.Joint != w.Joint {
+					t.Errorf("violation %d = %s, want cmd[%d].%s joint %d", i, v, w.Cmd, w.Location, w.Joint+1)
+				}
+			}
+		})
+	}
+}